@@ -0,0 +1,348 @@
+package cgen
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+)
+
+// Canonical returns the description in its canonical form: keys in the order of the fields they set,
+// with the lists of arguments and commands last, keys equal to their default dropped, two spaces of
+// indentation, strings quoted only when needed and written as literal blocks when they span several
+// lines. Comments are kept, and so are the keys that have comments, even if they are equal to their
+// default. Single blank lines between entries are kept. Only YAML descriptions can be formatted.
+func (s *Spec) Canonical() ([]byte, error) {
+	if s.Format != "yaml" {
+		return nil, fmt.Errorf("only YAML descriptions can be formatted, %s is %s", s.File, strings.ToUpper(s.Format))
+	}
+
+	f := canonicalWriter{lines: s.lines}
+	if len(s.lines) > 0 && strings.TrimSpace(s.lines[0]) == "---" {
+		f.line(0, "---")
+	}
+	if s.original != nil {
+		if _, ok := mappingEntries(s.original); !ok {
+			return nil, errors.New("the description is not a mapping")
+		}
+		f.mapping(s.original, reflect.TypeFor[CLI](), 0, false)
+	}
+	return []byte(f.String()), nil
+}
+
+type canonicalWriter struct {
+	strings.Builder
+
+	// Lines of the original description.
+	lines []string
+}
+
+// blankLine writes a blank line if there is one before the node, or before its head comment, in the
+// original description.
+func (f *canonicalWriter) blankLine(node ast.Node, head *ast.CommentGroupNode) {
+	tk := node.GetToken()
+	if head != nil && len(head.Comments) > 0 {
+		tk = head.Comments[0].Token
+	}
+	if tk == nil || tk.Position == nil || f.Len() == 0 {
+		return
+	}
+	if line := tk.Position.Line; line >= 2 && line-2 < len(f.lines) && strings.TrimSpace(f.lines[line-2]) == "" {
+		f.WriteString("\n")
+	}
+}
+
+func (f *canonicalWriter) line(indent int, text string) {
+	f.WriteString(strings.Repeat(" ", indent) + text + "\n")
+}
+
+// comment writes the lines of a head or foot comment.
+func (f *canonicalWriter) comment(indent int, c *ast.CommentGroupNode) {
+	if c == nil {
+		return
+	}
+	for _, comment := range c.Comments {
+		f.line(indent, "#"+comment.Token.Value)
+	}
+}
+
+// lineComment returns the comment that follows a value on the same line, prefixed by a space.
+func lineComment(nodes ...ast.Node) string {
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		if c := node.GetComment(); c != nil && len(c.Comments) > 0 {
+			return " #" + c.Comments[0].Token.Value
+		}
+	}
+	return ""
+}
+
+// mapping writes the entries of node, which decodes into a value of type t. If item is set, the
+// mapping is an item of a sequence and its first key follows the dash of the item.
+func (f *canonicalWriter) mapping(node ast.Node, t reflect.Type, indent int, item bool) {
+	fields := specFields(t)
+	kept := keptEntries(node, t)
+	if len(kept) == 0 {
+		if item {
+			f.line(indent-2, "- {}")
+		}
+		return
+	}
+	for i, e := range kept {
+		lead := strings.Repeat(" ", indent)
+		if item && i == 0 {
+			lead = strings.Repeat(" ", indent-2) + "- "
+		}
+		var field reflect.Type
+		if i := fieldIndex(fields, e); i >= 0 && i < len(fields) {
+			field = fields[i].Type
+		}
+		f.entry(e, field, indent, lead)
+	}
+	if m, ok := unwrapNode(node).(*ast.MappingNode); ok {
+		f.comment(indent, m.FootComment)
+	}
+}
+
+// keptEntries returns the entries of node, which decodes into a value of type t, in the canonical
+// order and without the ones equal to their default.
+func keptEntries(node ast.Node, t reflect.Type) []*ast.MappingValueNode {
+	entries, _ := mappingEntries(node)
+	fields := specFields(t)
+	order := func(e *ast.MappingValueNode) int {
+		i := fieldIndex(fields, e)
+		if i >= 0 && i < len(fields) && fields[i].Type.Kind() == reflect.Slice && elemType(fields[i].Type).Kind() == reflect.Struct {
+			return i + len(fields)
+		}
+		return i
+	}
+	entries = slices.Clone(entries)
+	slices.SortStableFunc(entries, func(a, b *ast.MappingValueNode) int { return order(a) - order(b) })
+
+	defaults := defaultValue(t)
+	kept := []*ast.MappingValueNode{}
+	for _, e := range entries {
+		i := fieldIndex(fields, e)
+		if i < 0 || i == len(fields) || hasComments(e) || !isDefault(e.Value, fields[i].Type, defaults.Field(fields[i].Index)) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// fieldIndex returns the index of the field set by the entry, -1 for merge keys and len(fields) for
+// unknown keys.
+func fieldIndex(fields []specField, e *ast.MappingValueNode) int {
+	if e.Key.IsMergeKey() {
+		return -1
+	}
+	i := slices.IndexFunc(fields, func(f specField) bool { return f.Name == e.Key.GetToken().Value })
+	if i < 0 {
+		return len(fields)
+	}
+	return i
+}
+
+// entry writes a key and its value, which decodes into a value of type t, if known.
+func (f *canonicalWriter) entry(e *ast.MappingValueNode, t reflect.Type, indent int, lead string) {
+	if !strings.HasSuffix(lead, "- ") {
+		f.blankLine(e.Key, e.GetComment())
+	}
+	f.comment(indent, e.GetComment())
+	key := e.Key.GetToken().Value
+	prefix := lead + key + ":"
+
+	value := e.Value
+	if anchor, ok := value.(*ast.AnchorNode); ok {
+		prefix += " &" + anchor.Name.GetToken().Value
+		value = anchor.Value
+		if c := lineComment(anchor.Name); c != "" {
+			prefix += c
+		}
+	}
+
+	switch v := value.(type) {
+	case *ast.AliasNode:
+		f.WriteString(prefix + " *" + v.Value.GetToken().Value + lineComment(v.Value, v) + "\n")
+	case *ast.MappingNode, *ast.MappingValueNode:
+		if entries, _ := mappingEntries(value); t != nil && t.Kind() == reflect.Map && len(entries) > 0 {
+			// The keys of maps are names, kept in their order, and their values are written by type.
+			f.WriteString(prefix + lineComment(e.Key) + "\n")
+			for _, item := range entries {
+				f.entry(item, t.Elem(), indent+2, strings.Repeat(" ", indent+2))
+			}
+			if m, ok := value.(*ast.MappingNode); ok {
+				f.comment(indent+2, m.FootComment)
+			}
+			break
+		}
+		if t == nil || elemType(t).Kind() != reflect.Struct {
+			f.WriteString(prefix + " " + strings.TrimSpace(value.String()) + "\n")
+			break
+		}
+		if len(keptEntries(value, elemType(t))) == 0 {
+			f.WriteString(prefix + " {}" + lineComment(e.Key) + "\n")
+			break
+		}
+		f.WriteString(prefix + lineComment(e.Key) + "\n")
+		f.mapping(value, elemType(t), indent+2, false)
+	case *ast.SequenceNode:
+		comment := lineComment(e.Key)
+		if v.IsFlowStyle {
+			comment = lineComment(e.Key, v)
+		}
+		if len(v.Values) == 0 {
+			f.WriteString(prefix + " []" + comment + "\n")
+			break
+		}
+		f.WriteString(prefix + comment + "\n")
+		f.sequence(v, t, indent+2)
+	case *ast.NullNode, nil:
+		f.WriteString(prefix + lineComment(value) + "\n")
+	default:
+		f.scalar(prefix, value, t, indent)
+	}
+	f.comment(indent, e.FootComment)
+}
+
+// sequence writes the items of seq, which decodes into a slice of type t, with their dashes indented
+// by indent.
+func (f *canonicalWriter) sequence(seq *ast.SequenceNode, t reflect.Type, indent int) {
+	var elem reflect.Type
+	if t != nil && t.Kind() == reflect.Slice {
+		elem = t.Elem()
+	}
+	for i, value := range seq.Values {
+		var head *ast.CommentGroupNode
+		if i < len(seq.ValueHeadComments) && seq.ValueHeadComments[i] != nil {
+			head = seq.ValueHeadComments[i]
+		} else if i == 0 && !seq.IsFlowStyle {
+			head = seq.GetComment()
+		}
+		if i > 0 && i < len(seq.Entries) && !seq.IsFlowStyle {
+			f.blankLine(seq.Entries[i], head)
+		}
+		f.comment(indent, head)
+
+		lead := strings.Repeat(" ", indent) + "- "
+		switch v := value.(type) {
+		case *ast.AnchorNode:
+			if _, ok := mappingEntries(v.Value); ok && elem != nil && elem.Kind() == reflect.Struct {
+				f.WriteString(lead + "&" + v.Name.GetToken().Value + lineComment(v.Name) + "\n")
+				f.mapping(v.Value, elem, indent+2, false)
+			} else {
+				f.scalar(lead+"&"+v.Name.GetToken().Value, v.Value, elem, indent)
+			}
+		case *ast.AliasNode:
+			f.WriteString(lead + "*" + v.Value.GetToken().Value + lineComment(v.Value, v) + "\n")
+		case *ast.MappingNode, *ast.MappingValueNode:
+			if elem == nil || elem.Kind() != reflect.Struct {
+				f.WriteString(lead + strings.TrimSpace(value.String()) + "\n")
+				break
+			}
+			f.mapping(value, elem, indent+2, true)
+		default:
+			f.scalar(strings.TrimSuffix(lead, " "), value, elem, indent)
+		}
+	}
+	f.comment(indent, seq.FootComment)
+}
+
+// scalar writes prefix followed by a scalar value, which decodes into a value of type t, if known.
+// Strings of unknown type, like the paths of includes, are quoted as strings too.
+func (f *canonicalWriter) scalar(prefix string, node ast.Node, t reflect.Type, indent int) {
+	comment := lineComment(node)
+	_, isString := node.(*ast.StringNode)
+	if t == nil && !isString || t != nil && t.Kind() != reflect.String {
+		f.WriteString(prefix + " " + node.GetToken().Value + comment + "\n")
+		return
+	}
+
+	text := node.GetToken().Value
+	if literal, ok := node.(*ast.LiteralNode); ok {
+		text = literal.Value.Value
+	}
+	if !strings.Contains(text, "\n") || strings.TrimRight(text, "\n") == "" {
+		quoted, _ := yaml.Marshal(text)
+		f.WriteString(prefix + " " + strings.TrimSuffix(string(quoted), "\n") + comment + "\n")
+		return
+	}
+
+	// Multi-line strings are written as literal blocks, with the chomping indicator that keeps their
+	// trailing newlines and, if the first line is indented, an explicit indentation.
+	header := "|"
+	if strings.HasPrefix(text, " ") {
+		header += "2"
+	}
+	content := strings.TrimRight(text, "\n")
+	switch len(text) - len(content) {
+	case 0:
+		header += "-"
+	case 1:
+	default:
+		header += "+"
+	}
+	f.WriteString(prefix + " " + header + comment + "\n")
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			f.WriteString("\n")
+		} else {
+			f.line(indent+2, line)
+		}
+	}
+	for range len(text) - len(content) - 1 {
+		f.WriteString("\n")
+	}
+}
+
+// elemType returns the type of the elements of slices and maps, and t itself otherwise.
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Map || t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// hasComments reports whether the entry, or the key or scalar value on its line, has a comment.
+func hasComments(e *ast.MappingValueNode) bool {
+	return e.GetComment() != nil || e.FootComment != nil || lineComment(e.Key, e.Value) != ""
+}
+
+// isDefault reports whether node decodes into the default value. Empty lists are considered equal to
+// missing ones, and anchors and aliases are never default, so that they are kept.
+func isDefault(node ast.Node, t reflect.Type, def reflect.Value) bool {
+	switch node.(type) {
+	case *ast.AnchorNode, *ast.AliasNode:
+		return false
+	}
+	v := reflect.New(t)
+	if err := yaml.NodeToValue(node, v.Interface()); err != nil {
+		return false
+	}
+	return equalValues(v.Elem(), def)
+}
+
+// equalValues reports whether a and b are deeply equal, considering empty slices and maps equal to
+// nil ones.
+func equalValues(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 || b.Len() == 0 {
+			return a.Len() == b.Len()
+		}
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if !equalValues(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -0,0 +1,114 @@
+package cgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"key order",
+			"commands:\n  - short-description: Adds\n    name: add\nname: cli\n",
+			"name: cli\ncommands:\n  - name: add\n    short-description: Adds\n",
+		},
+		{
+			"indentation",
+			"name: cli\ncommands:\n    -   name: add\n        aliases:\n            - a\n",
+			"name: cli\ncommands:\n  - name: add\n    aliases:\n      - a\n",
+		},
+		{
+			"defaults",
+			"name: cli\narguments:\n  - name: file\n    named: false\n    hidden: false\n",
+			"name: cli\narguments:\n  - name: file\n",
+		},
+		{
+			"quotes",
+			"name: \"cli\"\nshort-description: 'Does: things'\n",
+			"name: cli\nshort-description: \"Does: things\"\n",
+		},
+		{
+			"multiline strings",
+			"name: cli\nlong-description: \"First line\\nSecond line\\n\"\n",
+			"name: cli\nlong-description: |\n  First line\n  Second line\n",
+		},
+		{
+			"comments and blank lines",
+			"---\n# The tool\nname: cli # its name\n\n# Its commands\ncommands:\n  - name: add\n    hidden: false # kept\n",
+			"---\n# The tool\nname: cli # its name\n\n# Its commands\ncommands:\n  - name: add\n    hidden: false # kept\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec("cli.yml", []byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := spec.Canonical()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Canonical() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCanonicalRoundTrip checks that formatting the descriptions of the test cases does not change
+// what they describe, and that formatted descriptions are left as they are.
+func TestCanonicalRoundTrip(t *testing.T) {
+	specs, err := filepath.Glob(filepath.Join("..", "test", "*", "cli.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range specs {
+		t.Run(filepath.Base(filepath.Dir(path)), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec, err := ParseSpec(path, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			formatted, err := spec.Canonical()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			again, err := ParseSpec(path, formatted)
+			if err != nil {
+				t.Fatalf("the formatted description cannot be read: %v\n%s", err, formatted)
+			}
+			if got, want := SpecHash(again.CLI), SpecHash(spec.CLI); got != want {
+				t.Errorf("hash = %s after formatting, want %s", got, want)
+			}
+			twice, err := again.Canonical()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(twice) != string(formatted) {
+				t.Errorf("formatting twice gives\n%s\nwant\n%s", twice, formatted)
+			}
+		})
+	}
+}
+
+func TestCanonicalOtherFormats(t *testing.T) {
+	for _, format := range []string{"json", "toml"} {
+		data := map[string]string{"json": `{"name": "cli"}`, "toml": `name = "cli"`}[format]
+		spec, err := ParseSpecFormat("cli."+format, []byte(data), format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := spec.Canonical(); err == nil {
+			t.Errorf("Canonical() of %s succeeded, want an error", format)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package cgen
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+)
+
+// definitionKinds are the keys of the definitions, in the order they are resolved.
+var definitionKinds = []string{"arguments", "groups", "commands"}
+
+// resolver replaces the arguments and commands that use a definition by the definition with their
+// keys merged in.
+type resolver struct {
+	spec *Spec
+	ds   *[]Diagnostic
+
+	// Entries of the definitions, by kind and name.
+	definitions map[string]map[string]*ast.MappingValueNode
+
+	// Resolved definitions, by "kind.name".
+	resolved map[string]ast.Node
+
+	// Definitions being resolved, to detect cycles.
+	stack []string
+
+	// Nodes already resolved, which may be shared by several uses of a definition.
+	done map[ast.Node]bool
+}
+
+// resolveDefinitions resolves the uses of definitions in the arguments and commands of the
+// description and of its definitions.
+func (s *Spec) resolveDefinitions(ds *[]Diagnostic) {
+	r := &resolver{
+		spec:        s,
+		ds:          ds,
+		definitions: map[string]map[string]*ast.MappingValueNode{},
+		resolved:    map[string]ast.Node{},
+		done:        map[ast.Node]bool{},
+	}
+	var definitions ast.Node
+	if entry := mappingEntry(s.root, "definitions"); entry != nil {
+		definitions = entry.Value
+	}
+	for _, kind := range definitionKinds {
+		r.definitions[kind] = map[string]*ast.MappingValueNode{}
+		if entry := mappingEntry(definitions, kind); entry != nil {
+			entries, _ := mappingEntries(entry.Value)
+			for _, e := range entries {
+				r.definitions[kind][e.Key.GetToken().Value] = e
+			}
+		}
+	}
+
+	for name, e := range r.definitions["groups"] {
+		if r.definitions["arguments"][name] != nil {
+			r.fail(e.Key, "duplicate-definition", fmt.Sprintf("%q is the name of both an argument and a group", name), "")
+		}
+	}
+	for _, kind := range definitionKinds {
+		for _, name := range slices.Sorted(maps.Keys(r.definitions[kind])) {
+			r.definition(kind, name, nil)
+		}
+	}
+	r.lists(s.root)
+}
+
+func (r *resolver) fail(node ast.Node, rule, message, suggestion string) {
+	d := Diagnostic{Severity: SeverityError, Rule: rule, Message: message, Suggestion: suggestion}
+	r.spec.locate(&d, node.GetToken())
+	*r.ds = append(*r.ds, d)
+}
+
+// definition returns the resolved definition of a kind with the given name, reporting uses of
+// unknown definitions and cycles at use, if given.
+func (r *resolver) definition(kind, name string, use ast.Node) ast.Node {
+	entry := r.definitions[kind][name]
+	if entry == nil {
+		names := slices.Sorted(maps.Keys(r.definitions[kind]))
+		if kind == "arguments" {
+			names = append(names, slices.Sorted(maps.Keys(r.definitions["groups"]))...)
+		}
+		r.fail(use, "unknown-definition", fmt.Sprintf("there is no definition of %s named %q", strings.TrimSuffix(kind, "s"), name), suggest(name, names))
+		return nil
+	}
+
+	key := kind + "." + name
+	if node, ok := r.resolved[key]; ok {
+		return node
+	}
+	if i := slices.Index(r.stack, key); i >= 0 {
+		cycle := append(slices.Clone(r.stack[i:]), key)
+		if use == nil {
+			use = entry.Key
+		}
+		r.fail(use, "definition-cycle", fmt.Sprintf("%s uses itself: %s", key, strings.Join(cycle, " -> ")), "")
+		return nil
+	}
+
+	r.stack = append(r.stack, key)
+	node := entry.Value
+	if kind == "groups" {
+		if seq, ok := unwrapNode(node).(*ast.SequenceNode); ok {
+			seq.Values = r.items(seq.Values, "arguments")
+		}
+	} else {
+		node = r.resolve(node, kind)
+	}
+	r.stack = r.stack[:len(r.stack)-1]
+	r.resolved[key] = node
+	return node
+}
+
+// resolve returns the argument or command in node merged into the definition it uses, if any, with
+// the uses in its lists resolved.
+func (r *resolver) resolve(node ast.Node, kind string) ast.Node {
+	if r.done[node] {
+		return node
+	}
+	if use := mappingEntry(node, "use"); use != nil {
+		if base := r.definition(kind, use.Value.GetToken().Value, use.Value); base != nil {
+			node = merge(base, node)
+		}
+	}
+	if kind == "commands" {
+		r.lists(node)
+	}
+	r.done[node] = true
+	return node
+}
+
+// lists resolves the uses in the lists of arguments and commands of a command.
+func (r *resolver) lists(node ast.Node) {
+	for _, kind := range []string{"arguments", "commands"} {
+		entry := mappingEntry(node, kind)
+		if entry == nil {
+			continue
+		}
+		if seq, ok := unwrapNode(entry.Value).(*ast.SequenceNode); ok && !r.done[seq] {
+			seq.Values = r.items(seq.Values, kind)
+			r.done[seq] = true
+		}
+	}
+}
+
+// items resolves the items of a list of arguments or commands, inserting the arguments of the groups
+// they use.
+func (r *resolver) items(values []ast.Node, kind string) []ast.Node {
+	items := []ast.Node{}
+	for _, value := range values {
+		use := mappingEntry(value, "use")
+		if use == nil || kind != "arguments" || r.definitions["groups"][use.Value.GetToken().Value] == nil {
+			items = append(items, r.resolve(value, kind))
+			continue
+		}
+
+		entries, _ := mappingEntries(value)
+		for _, e := range entries {
+			if e != use {
+				r.fail(e.Key, "invalid-value", "the arguments of a group cannot be overridden", "")
+				break
+			}
+		}
+		group := r.definition("groups", use.Value.GetToken().Value, use.Value)
+		if seq, ok := unwrapNode(group).(*ast.SequenceNode); ok {
+			items = append(items, seq.Values...)
+		}
+	}
+	return items
+}
+
+// merge returns a mapping with the entries of base replaced by the entries of over with the same key,
+// followed by the other entries of over. Values that are mappings in both are merged too.
+func merge(base, over ast.Node) ast.Node {
+	baseEntries, _ := mappingEntries(base)
+	overEntries, _ := mappingEntries(over)
+	find := func(key string) *ast.MappingValueNode {
+		i := slices.IndexFunc(overEntries, func(e *ast.MappingValueNode) bool { return e.Key.GetToken().Value == key })
+		if i < 0 {
+			return nil
+		}
+		return overEntries[i]
+	}
+
+	entries := []*ast.MappingValueNode{}
+	for _, e := range baseEntries {
+		key := e.Key.GetToken().Value
+		o := find(key)
+		switch {
+		case key == "use":
+		case o == nil:
+			entries = append(entries, e)
+		default:
+			_, baseMapping := mappingEntries(e.Value)
+			_, overMapping := mappingEntries(o.Value)
+			if baseMapping && overMapping {
+				merged := *o
+				merged.Value = merge(e.Value, o.Value)
+				o = &merged
+			}
+			entries = append(entries, o)
+		}
+	}
+	for _, o := range overEntries {
+		if !slices.ContainsFunc(baseEntries, func(e *ast.MappingValueNode) bool {
+			return e.Key.GetToken().Value == o.Key.GetToken().Value && e.Key.GetToken().Value != "use"
+		}) {
+			entries = append(entries, o)
+		}
+	}
+	return ast.Mapping(unwrapNode(over).GetToken(), false, entries...)
+}
@@ -0,0 +1,132 @@
+package cgen
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDefinitions(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+
+		// A description without definitions that in resolves to.
+		want string
+	}{
+		{
+			"argument",
+			"definitions:\n  arguments:\n    verbose: {named: true, name: verbose, short-name: v}\n" +
+				"arguments:\n  - use: verbose\n",
+			"arguments:\n  - {named: true, name: verbose, short-name: v}\n",
+		},
+		{
+			"overridden keys",
+			"definitions:\n  arguments:\n    file:\n      name: file\n      short-description: A file\n      completion: {type: file, values: [a]}\n" +
+				"arguments:\n  - use: file\n    short-description: The input\n    completion: {type: static}\n",
+			"arguments:\n  - name: file\n    short-description: The input\n    completion: {type: static, values: [a]}\n",
+		},
+		{
+			"group",
+			"definitions:\n  groups:\n    common:\n      - {named: true, name: verbose}\n      - {named: true, name: quiet}\n" +
+				"arguments:\n  - {named: true, name: help}\n  - use: common\n  - name: file\n",
+			"arguments:\n  - {named: true, name: help}\n  - {named: true, name: verbose}\n  - {named: true, name: quiet}\n  - name: file\n",
+		},
+		{
+			"command using definitions",
+			"definitions:\n  arguments:\n    namespace: {named: true, name: namespace}\n" +
+				"  groups:\n    common:\n      - use: namespace\n" +
+				"  commands:\n    resource:\n      short-description: Shows resources\n      arguments:\n        - use: common\n" +
+				"commands:\n  - use: resource\n    name: get\n  - use: resource\n    name: describe\n",
+			"commands:\n" +
+				"  - {name: get, short-description: Shows resources, arguments: [{named: true, name: namespace}]}\n" +
+				"  - {name: describe, short-description: Shows resources, arguments: [{named: true, name: namespace}]}\n",
+		},
+		{
+			"definition using a definition",
+			"definitions:\n  arguments:\n    base: {name: base, short-description: Base}\n    derived: {use: base, name: derived}\n" +
+				"arguments:\n  - use: derived\n",
+			"arguments:\n  - {name: derived, short-description: Base}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec("cli.yml", []byte("name: cli\n"+tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ParseSpec("want.yml", []byte("name: cli\n"+tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := SpecHash(spec.CLI), SpecHash(want.CLI); got != want {
+				t.Errorf("the description does not resolve to\n%s", tt.want)
+			}
+		})
+	}
+}
+
+func TestDefinitionErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         string
+		rule       string
+		position   string
+		suggestion string
+	}{
+		{
+			"unknown argument",
+			"definitions:\n  arguments:\n    verbose: {name: verbose}\narguments:\n  - use: verbos\n",
+			"unknown-definition", "6:10", "verbose",
+		},
+		{
+			"unknown command",
+			"commands:\n  - use: resource\n",
+			"unknown-definition", "3:10", "",
+		},
+		{
+			"cycle",
+			"definitions:\n  arguments:\n    a: {use: b}\n    b: {use: a}\n",
+			"definition-cycle", "5:14", "",
+		},
+		{
+			"argument and group with the same name",
+			"definitions:\n  arguments:\n    common: {name: common}\n  groups:\n    common:\n      - {name: file}\n",
+			"duplicate-definition", "6:5", "",
+		},
+		{
+			"overridden group",
+			"definitions:\n  groups:\n    common:\n      - {name: file}\narguments:\n  - use: common\n    name: other\n",
+			"invalid-value", "8:5", "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec("cli.yml", []byte("name: cli\n"+tt.in))
+			ds := specDiagnostics(t, err)
+			if len(ds) != 1 || ds[0].Rule != tt.rule {
+				t.Fatalf("diagnostics = %v, want one %s", ds, tt.rule)
+			}
+			if p := ds[0].Position; p == nil || fmt.Sprintf("%d:%d", p.Line, p.Column) != tt.position {
+				t.Errorf("position = %v, want %s", p, tt.position)
+			}
+			if ds[0].Suggestion != tt.suggestion {
+				t.Errorf("suggestion = %q, want %q", ds[0].Suggestion, tt.suggestion)
+			}
+		})
+	}
+}
+
+func TestDefinitionAtUse(t *testing.T) {
+	in := "name: cli\ndefinitions:\n  arguments:\n    verbose: {name: verbose}\narguments:\n  - use: verbose\n"
+	spec, err := ParseSpec("cli.yml", []byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := spec.DefinitionAt(6, 12)
+	if len(ps) != 1 || ps[0].Line != 4 || ps[0].Column != 5 {
+		t.Errorf("DefinitionAt(6, 12) = %v, want the key of the definition at 4:5", ps)
+	}
+	if ps := spec.DefinitionAt(1, 1); ps != nil {
+		t.Errorf("DefinitionAt(1, 1) = %v, want nil", ps)
+	}
+}
@@ -0,0 +1,178 @@
+package cgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Diagnostic is a problem found in a CLI description.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+
+	// Stable identifier of the rule that produced the diagnostic, e.g. duplicate-short-name.
+	Rule string `json:"rule"`
+
+	// Location of the offending value in the description file, e.g. commands[0].arguments[1].
+	Path string `json:"path,omitempty"`
+
+	Message string `json:"message"`
+
+	// Where the offending value is in the description file, if known.
+	Position *Position `json:"position,omitempty"`
+
+	// Source lines around Position, with the offending value marked by carets.
+	Snippet string `json:"snippet,omitempty"`
+
+	// A likely fix, like the correct spelling of a misspelled key.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Position is a location in a file. Lines and columns start at 1.
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+
+	// Length of the marked value, in characters.
+	Length int `json:"length"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.Position != nil {
+		b.WriteString(d.Position.String() + ": ")
+	}
+	b.WriteString(d.Severity.String() + ": ")
+	if d.Path != "" {
+		b.WriteString(d.Path + ": ")
+	}
+	b.WriteString(fmt.Sprintf("%s (%s)", d.Message, d.Rule))
+	return b.String()
+}
+
+// Rule describes a kind of diagnostic.
+type Rule struct {
+	ID          string
+	Description string
+	Severity    Severity
+}
+
+var rules = []Rule{
+	{"syntax-error", "The file is not valid YAML, JSON or TOML.", SeverityError},
+	{"decode-error", "A value has the wrong type.", SeverityError},
+	{"include-not-found", "An included file does not exist or cannot be read.", SeverityError},
+	{"include-cycle", "A file includes itself, directly or through other files.", SeverityError},
+	{"unknown-definition", "An argument or command uses a definition that does not exist.", SeverityError},
+	{"definition-cycle", "A definition uses itself, directly or through other definitions.", SeverityError},
+	{"duplicate-definition", "An argument and a group of arguments share the same name.", SeverityError},
+	{"overlay-not-found", "An overlay file does not exist or cannot be read.", SeverityError},
+	{"overlay-path-not-found", "An overlay operation names a command or argument that does not exist.", SeverityError},
+	{"undefined-variable", "A text references a variable that is not defined.", SeverityError},
+	{"unsupported-spec-version", "The spec-version is unknown, or a key needs a newer one.", SeverityError},
+	{"unknown-field", "A key is not part of the format.", SeverityError},
+	{"missing-field", "A required key is missing.", SeverityError},
+	{"invalid-value", "A value is not one of the accepted ones.", SeverityError},
+	{"duplicate-name", "Two options of a command share the same long name.", SeverityError},
+	{"duplicate-short-name", "Two options of a command share the same short name.", SeverityError},
+	{"duplicate-command-name", "Two sibling commands share the same name.", SeverityError},
+	{"alias-collision", "An alias is the name or alias of a sibling command.", SeverityError},
+	{"empty-static-completion", "A static completion has no values.", SeverityError},
+	{"shadowed-global-option", "A command option has the same name as a global option.", SeverityWarning},
+	{"missing-function-snippet", "A function completion or a provider has no code for some shell.", SeverityWarning},
+	{"missing-spec-version", "A description without spec-version has a value that a newer version reads differently.", SeverityWarning},
+	{"value-label-without-value", "A value label is set on an option that takes no value.", SeverityWarning},
+}
+
+// Rules returns every rule that diagnostics can refer to. Their identifiers are stable.
+func Rules() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+// DiagnosticError is returned when a description cannot be used. It lists all problems found.
+type DiagnosticError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *DiagnosticError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(ds []Diagnostic) bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the candidate closest to word, or an empty string if none is close enough to be a
+// likely misspelling.
+func suggest(word string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(word), strings.ToLower(c))
+		if word != "" && strings.HasPrefix(c, word) {
+			d = min(d, 1)
+		}
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(word)/3) {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
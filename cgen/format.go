@@ -0,0 +1,88 @@
+package cgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// SpecFormats are the formats a CLI description can be written in.
+var SpecFormats = []string{"yaml", "json", "toml"}
+
+// SpecFormat returns the format of a description file from its extension. Files with other
+// extensions are considered YAML.
+func SpecFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return "json"
+	case ".toml":
+		return "toml"
+	}
+	return "yaml"
+}
+
+// parseYAML parses data twice: the includes are expanded in the tree used for decoding, while the
+// other is kept as written, for formatting.
+func (s *Spec) parseYAML(data []byte) error {
+	for _, root := range []*ast.Node{&s.root, &s.original} {
+		f, err := parser.ParseBytes(data, parser.ParseComments)
+		if err != nil {
+			return &DiagnosticError{Diagnostics: []Diagnostic{s.decodeDiagnostic(s.root, err)}}
+		}
+		if len(f.Docs) > 0 {
+			*root = f.Docs[0].Body
+		}
+	}
+	return nil
+}
+
+// parseJSON checks that data is strict JSON, then parses it as YAML, which JSON is a subset of, so
+// that positions are kept.
+func (s *Spec) parseJSON(data []byte) error {
+	var v any
+	err := json.Unmarshal(data, &v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		d := Diagnostic{Severity: SeverityError, Rule: "syntax-error", Message: syntaxErr.Error()}
+		before := data[:max(syntaxErr.Offset-1, 0)]
+		line := bytes.Count(before, []byte("\n")) + 1
+		column := len(before) - bytes.LastIndexByte(before, '\n')
+		mark(&d, s.sourceOf(nil), line, column, 1)
+		return &DiagnosticError{Diagnostics: []Diagnostic{d}}
+	} else if err != nil {
+		return &DiagnosticError{Diagnostics: []Diagnostic{{Severity: SeverityError, Rule: "syntax-error", Message: err.Error()}}}
+	}
+	return s.parseYAML(data)
+}
+
+// parseTOML decodes data and converts the result to a YAML node. The positions of the values are
+// lost, so only syntax errors are located in the file.
+func (s *Spec) parseTOML(data []byte) error {
+	var v map[string]any
+	_, err := toml.Decode(string(data), &v)
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		d := Diagnostic{Severity: SeverityError, Rule: "syntax-error", Message: parseErr.Message}
+		mark(&d, s.sourceOf(nil), parseErr.Position.Line, parseErr.Position.Col, parseErr.Position.Len)
+		return &DiagnosticError{Diagnostics: []Diagnostic{d}}
+	} else if err != nil {
+		return &DiagnosticError{Diagnostics: []Diagnostic{{Severity: SeverityError, Rule: "syntax-error", Message: err.Error()}}}
+	}
+
+	if len(v) == 0 {
+		return nil
+	}
+	root, err := yaml.ValueToNode(v)
+	if err != nil {
+		return &DiagnosticError{Diagnostics: []Diagnostic{{Severity: SeverityError, Rule: "decode-error", Message: err.Error()}}}
+	}
+	s.root = root
+	return nil
+}
@@ -0,0 +1,297 @@
+package cgen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Generator produces a single target, like a shell completion script or a man page, from a CLI
+// description.
+type Generator interface {
+	// Name of the target, e.g. "bash". It is how the target is selected from the command line.
+	Name() string
+
+	// Path of the generated file, relative to the output root, e.g. "bash/completions/cli.bash".
+	DefaultPath(cli *CLI) string
+
+	// Writes the generated contents to w.
+	Write(cli *CLI, w io.Writer) error
+}
+
+// MultiFileGenerator is implemented by generators that produce more than one file, like the man
+// page generator, which writes a page for each command.
+type MultiFileGenerator interface {
+	Generator
+
+	// All the files produced by the generator. The first one is the file written by Write.
+	Files(cli *CLI) []File
+}
+
+// File is a single file produced by a generator.
+type File struct {
+	// Path relative to the output root.
+	Path string
+
+	// Writes the contents of the file to w.
+	Write func(w io.Writer) error
+}
+
+var (
+	generatorsMu sync.RWMutex
+	generators   []Generator
+)
+
+func init() {
+	Register(bashGenerator{})
+	Register(fishGenerator{})
+	Register(zshGenerator{})
+	Register(powershellGenerator{})
+	Register(nushellGenerator{})
+	Register(elvishGenerator{})
+	Register(tcshGenerator{})
+	Register(emacsGenerator{})
+	Register(manGenerator{})
+}
+
+// Register makes a generator available by its name. It panics if a generator with the same name is
+// already registered.
+func Register(g Generator) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	for _, other := range generators {
+		if other.Name() == g.Name() {
+			panic(fmt.Sprintf("cgen: generator %q registered twice", g.Name()))
+		}
+	}
+	generators = append(generators, g)
+}
+
+// Generators returns all registered generators, in registration order.
+func Generators() []Generator {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	return append([]Generator(nil), generators...)
+}
+
+// LookupGenerator returns the generator registered with the given name.
+func LookupGenerator(name string) (Generator, bool) {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	for _, g := range generators {
+		if g.Name() == name {
+			return g, true
+		}
+	}
+	return nil, false
+}
+
+// GeneratorFiles returns the files produced by g.
+func GeneratorFiles(g Generator, cli *CLI) []File {
+	if mg, ok := g.(MultiFileGenerator); ok {
+		return mg.Files(cli)
+	}
+	return []File{{
+		Path:  g.DefaultPath(cli),
+		Write: func(w io.Writer) error { return g.Write(cli, w) },
+	}}
+}
+
+// WriteFiles writes all files produced by g under the root directory.
+func WriteFiles(g Generator, cli *CLI, root string) error {
+	for _, f := range GeneratorFiles(g, cli) {
+		if err := WriteFile(root, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes f under the root directory, creating any missing parent directory. If the path
+// of f is absolute, root is ignored.
+func WriteFile(root string, f File) error {
+	path := f.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	// Generate everything before touching the file, so that it is not left truncated on errors.
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not create file: %w", err)
+	}
+
+	return nil
+}
+
+// providerFunction returns the name of the shell function that generators write for a provider.
+func providerFunction(cliName, provider string) string {
+	return "__" + shellIdentifier(cliName) + "_provider_" + shellIdentifier(provider)
+}
+
+// shellIdentifier replaces the characters that cannot be part of the name of a shell function.
+func shellIdentifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// functionCode returns the code that prints the completions of a function completion: a call of the
+// function of its provider, if it has one, or code, its own code for the shell.
+func functionCode(cliName string, c Completion, code string) string {
+	if c.Provider != "" {
+		return providerFunction(cliName, c.Provider)
+	}
+	return code
+}
+
+// providerNames returns the names of the providers in the order the generators write them.
+func providerNames(cli *CLI) []string {
+	return slices.Sorted(maps.Keys(cli.Providers))
+}
+
+// functionBody returns the lines of the body of a shell function, without trailing empty lines. An
+// empty body is replaced by a no-op.
+func functionBody(code string) []string {
+	code = strings.TrimRight(code, "\n ")
+	if strings.TrimSpace(code) == "" {
+		return []string{":"}
+	}
+	return strings.Split(code, "\n")
+}
+
+// flatCommand is the tool, or one of its commands, with the options and positional arguments it
+// accepts. Its key is the path of names that leads to it, joined by semicolons, like "git;remote;add".
+type flatCommand struct {
+	key         string
+	options     []Argument
+	positionals []Argument
+	subcommands []Command
+
+	// Number of options, at the end of options, that are global options of the tool.
+	globals int
+}
+
+// optionOwner returns the key of the command that declares the i-th option of c.
+func (c flatCommand) optionOwner(i int) string {
+	if i >= len(c.options)-c.globals {
+		return strings.SplitN(c.key, ";", 2)[0]
+	}
+	return c.key
+}
+
+// flattenCommands returns the tool and every command under it, each before its subcommands. The
+// global options are added to the options of each command.
+func flattenCommands(cli *CLI) []flatCommand {
+	commands := []flatCommand{{
+		key:         cli.Name,
+		options:     namedArguments(cli.Arguments),
+		positionals: collectPositionalArgs(cli.Arguments),
+		subcommands: cli.Commands,
+	}}
+	return append(commands, flattenSubcommands(cli.Name, cli.Commands, namedArguments(cli.Arguments))...)
+}
+
+func flattenSubcommands(key string, cmds []Command, globals []Argument) []flatCommand {
+	commands := []flatCommand{}
+	for _, cmd := range cmds {
+		cmdKey := key + ";" + cmd.Name
+		commands = append(commands, flatCommand{
+			key:         cmdKey,
+			options:     append(namedArguments(cmd.Arguments), globals...),
+			positionals: collectPositionalArgs(cmd.Arguments),
+			subcommands: cmd.Subcommands,
+			globals:     len(globals),
+		})
+		commands = append(commands, flattenSubcommands(cmdKey, cmd.Subcommands, globals)...)
+	}
+	return commands
+}
+
+// namedArguments returns the options among args.
+func namedArguments(args []Argument) []Argument {
+	named := []Argument{}
+	for _, arg := range args {
+		if arg.Named {
+			named = append(named, arg)
+		}
+	}
+	return named
+}
+
+// optionNames returns the words of an option, like -v and --verbose.
+func optionNames(arg Argument) ([]string, error) {
+	if _, err := spacedOptionNames(arg); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	if arg.ShortName != "" {
+		names = append(names, "-"+arg.ShortName)
+	}
+	if arg.Name != "" {
+		if arg.SingleDashLong {
+			names = append(names, "-"+arg.Name)
+		} else {
+			names = append(names, "--"+arg.Name)
+		}
+	}
+	return names, nil
+}
+
+// attachedOptionNames returns the short option of an argument whose value can be attached to it, like
+// -ofile. The separators must have been validated, as spacedOptionNames does.
+func attachedOptionNames(arg Argument) []string {
+	if arg.Completion.Type == "none" || arg.ShortName == "" || arg.ShortValueSeparator == "space" {
+		return nil
+	}
+	return []string{"-" + arg.ShortName}
+}
+
+// spacedOptionNames returns the words of an option that are followed by its value, as a separate
+// word.
+func spacedOptionNames(arg Argument) ([]string, error) {
+	switch arg.LongValueSeparator {
+	case "space", "equal", "both":
+	default:
+		return nil, errInvalidValue(arg, "long-value-separator", arg.LongValueSeparator, longValueSeparators)
+	}
+	switch arg.ShortValueSeparator {
+	case "space", "attached", "both":
+	default:
+		return nil, errInvalidValue(arg, "short-value-separator", arg.ShortValueSeparator, shortValueSeparators)
+	}
+
+	names := []string{}
+	if arg.Completion.Type == "none" {
+		return names, nil
+	}
+	if arg.ShortName != "" && arg.ShortValueSeparator != "attached" {
+		names = append(names, "-"+arg.ShortName)
+	}
+	if arg.Name != "" && arg.LongValueSeparator != "equal" {
+		if arg.SingleDashLong {
+			names = append(names, "-"+arg.Name)
+		} else {
+			names = append(names, "--"+arg.Name)
+		}
+	}
+	return names, nil
+}
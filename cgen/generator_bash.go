@@ -3,8 +3,6 @@ package cgen
 import (
 	"fmt"
 	"io"
-	"log"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -12,25 +10,32 @@ import (
 	"al.essio.dev/pkg/shellescape"
 )
 
-func GenerateBashCompletions(cli *CLI) error {
-	dir := filepath.Join("share", "bash", "completions")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("could not create directory: %w", err)
-	}
+type bashGenerator struct{}
 
-	path := filepath.Join(dir, fmt.Sprintf("%s.bash", cli.Name))
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("could not create file: %w", err)
-	}
-	defer file.Close()
+func (bashGenerator) Name() string {
+	return "bash"
+}
+
+func (bashGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("bash", "completions", fmt.Sprintf("%s.bash", cli.Name))
+}
 
-	return writeBashCompletions(cli, file)
+func (bashGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeBashCompletions(cli, w)
+}
+
+func GenerateBashCompletions(cli *CLI) error {
+	return WriteFiles(bashGenerator{}, cli, "share")
 }
 
 func writeBashCompletions(cli *CLI, w io.Writer) error {
 	iw := newIndentedWriter(w, "  ")
 
+	if err := writeHeader(w, cli, "#"); err != nil {
+		return err
+	}
+	iw.WriteLine("\n")
+
 	// Write helper function
 	iw.WriteLine(`__bash_seen_word() {
   local word
@@ -53,10 +58,26 @@ index_of() {
 
 `)
 
+	for _, name := range providerNames(cli) {
+		iw.WriteLine(fmt.Sprintf("%s() {\n", providerFunction(cli.Name, name)))
+		iw.Indent(func() error {
+			for _, line := range functionBody(cli.Providers[name].Bash) {
+				iw.WriteLine(line + "\n")
+			}
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+	}
+
+	globalOptions, err := collectArguments(cli.Name, cli.Arguments)
+	if err != nil {
+		return err
+	}
+
 	// _complete_command() stub
 	iw.WriteLine(fmt.Sprintf("_complete_command_%s() {\n", cli.Name))
-	iw.Indent(func() error {
-		writeBashArray(iw, "global_options", collectArguments(cli.Arguments))
+	err = iw.Indent(func() error {
+		writeBashArray(iw, "global_options", globalOptions)
 		iw.WriteLine("prev=${COMP_WORDS[$((COMP_CWORD-1))]}\n")
 		for _, cmd := range cli.Commands {
 			if err := completeCommandBash(iw, cli, &cmd); err != nil {
@@ -65,12 +86,15 @@ index_of() {
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 	iw.WriteLine("}\n\n")
 
 	// main CLI function
 	iw.WriteLine(fmt.Sprintf("_%s() {\n", cli.Name))
-	iw.Indent(func() error {
-		writeBashArray(iw, "global_options", collectArguments(cli.Arguments))
+	err = iw.Indent(func() error {
+		writeBashArray(iw, "global_options", globalOptions)
 		writeBashArray(iw, "commands", collectCommands(cli.Commands))
 		iw.WriteLine("for command in \"${commands[@]}\"; do\n")
 		iw.Indent(func() error {
@@ -92,7 +116,7 @@ index_of() {
 			return nil
 		})
 		iw.WriteLine("fi\n")
-		if err := writeArgumentCaseSwitch(iw, cli.Arguments); err != nil {
+		if err := writeArgumentCaseSwitch(iw, cli.Name, cli.Arguments); err != nil {
 			return err
 		}
 
@@ -100,6 +124,9 @@ index_of() {
 		iw.WriteLine("COMPREPLY=( $(compgen -W \"${completions[*]}\" -- \"${COMP_WORDS[COMP_CWORD]}\") )\n")
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 	iw.WriteLine(fmt.Sprintf("}\n\ncomplete -o bashdefault -F _%s %s\n", cli.Name, cli.Name))
 	return nil
 }
@@ -113,7 +140,7 @@ func writeBashArray(w *indentedWriter, name string, values []string) {
 	w.WriteLine(fmt.Sprintf("%s=(%s)\n", name, strings.Join(escaped, " ")))
 }
 
-func collectArguments(args []Argument) []string {
+func collectArguments(cliName string, args []Argument) ([]string, error) {
 	out := []string{}
 	for _, arg := range args {
 		if arg.Named {
@@ -133,8 +160,7 @@ func collectArguments(args []Argument) []string {
 					out = append(out, name)
 					out = append(out, name+"=")
 				default:
-					log.Fatalf("The option %s is not valid for long-value-separator. Accepted values are space, equal and both", arg.LongValueSeparator)
-					os.Exit(1)
+					return nil, errInvalidValue(arg, "long-value-separator", arg.LongValueSeparator, longValueSeparators)
 				}
 			}
 			if arg.ShortName != "" {
@@ -147,11 +173,11 @@ func collectArguments(args []Argument) []string {
 					out = append(out, shellescape.Quote(value))
 				}
 			case "function":
-				out = append(out, fmt.Sprintf("$(%s)", arg.Completion.Bash))
+				out = append(out, fmt.Sprintf("$(%s)", functionCode(cliName, arg.Completion, arg.Completion.Bash)))
 			}
 		}
 	}
-	return out
+	return out, nil
 }
 
 func collectCommands(cmds []Command) []string {
@@ -170,17 +196,21 @@ func completeCommandBash(w *indentedWriter, cli *CLI, cmd *Command) error {
 		w.WriteLine(fmt.Sprintf("if __bash_seen_word %s; then\n", shellescape.Quote(name)))
 		err := w.Indent(func() error {
 			for _, sub := range cmd.Subcommands {
-				err := completeSubcommandBash(w, []string{name}, &sub, cli.Arguments)
+				err := completeSubcommandBash(w, []string{name}, &sub, cli)
 				if err != nil {
 					return err
 				}
 			}
 
-			writeBashArray(w, "arguments", collectArguments(cmd.Arguments))
+			arguments, err := collectArguments(cli.Name, cmd.Arguments)
+			if err != nil {
+				return err
+			}
+			writeBashArray(w, "arguments", arguments)
 			writeBashArray(w, "subcommands", collectCommands(cmd.Subcommands))
 			w.WriteLine(fmt.Sprintf("pos=$((${#COMP_WORDS[@]} - $(index_of %s) - 1))\n", cmd.Name))
 
-			if err := writeArgumentCaseSwitch(w, cmd.Arguments); err != nil {
+			if err := writeArgumentCaseSwitch(w, cli.Name, cmd.Arguments); err != nil {
 				return err
 			}
 
@@ -199,24 +229,28 @@ func completeCommandBash(w *indentedWriter, cli *CLI, cmd *Command) error {
 	return nil
 }
 
-func completeSubcommandBash(w *indentedWriter, path []string, subcmd *Command, globalArgs []Argument) error {
+func completeSubcommandBash(w *indentedWriter, path []string, subcmd *Command, cli *CLI) error {
 	names := append([]string{subcmd.Name}, subcmd.Aliases...)
 
 	for _, name := range names {
 		w.WriteLine(fmt.Sprintf("if __bash_seen_word %s; then\n", shellescape.Quote(name)))
 		err := w.Indent(func() error {
 			for _, sub := range subcmd.Subcommands {
-				err := completeSubcommandBash(w, append(path, name), &sub, globalArgs)
+				err := completeSubcommandBash(w, append(path, name), &sub, cli)
 				if err != nil {
 					return err
 				}
 			}
 
-			writeBashArray(w, "arguments", collectArguments(subcmd.Arguments))
+			arguments, err := collectArguments(cli.Name, subcmd.Arguments)
+			if err != nil {
+				return err
+			}
+			writeBashArray(w, "arguments", arguments)
 			writeBashArray(w, "subcommands", collectCommands(subcmd.Subcommands))
 			w.WriteLine(fmt.Sprintf("pos=$((${#COMP_WORDS[@]} - $(index_of %s) - 1))\n", subcmd.Name))
 
-			if err := writeArgumentCaseSwitch(w, subcmd.Arguments); err != nil {
+			if err := writeArgumentCaseSwitch(w, cli.Name, subcmd.Arguments); err != nil {
 				return err
 			}
 
@@ -235,7 +269,7 @@ func completeSubcommandBash(w *indentedWriter, path []string, subcmd *Command, g
 	return nil
 }
 
-func writeArgumentCaseSwitch(w *indentedWriter, args []Argument) error {
+func writeArgumentCaseSwitch(w *indentedWriter, cliName string, args []Argument) error {
 	switch_prev_written := false
 	w.WriteLine("cur=${COMP_WORDS[$COMP_CWORD]}\n")
 	w.WriteLine("case \"$cur\" in\n")
@@ -277,8 +311,7 @@ swith_body:
 					keys = append(keys, name+"=")
 				}
 			default:
-				log.Fatalf("The option %s is not valid for long-value-separator. Accepted values are space, equal and both", arg.LongValueSeparator)
-				os.Exit(1)
+				return errInvalidValue(arg, "long-value-separator", arg.LongValueSeparator, longValueSeparators)
 			}
 		}
 		if arg.Completion.Type != "none" && len(keys) > 0 {
@@ -302,7 +335,7 @@ swith_body:
 					case "folder":
 						w.WriteLine("COMPREPLY=( $(compgen -d -- \"${COMP_WORDS[COMP_CWORD]}\") )\n")
 					case "function":
-						w.WriteLine(fmt.Sprintf("values=($(%s))\n", arg.Completion.Bash))
+						w.WriteLine(fmt.Sprintf("values=($(%s))\n", functionCode(cliName, arg.Completion, arg.Completion.Bash)))
 						if !switch_prev_written {
 							w.WriteLine(fmt.Sprintf("values=(${values[@]/#/%s})\n", key))
 						}
@@ -342,7 +375,7 @@ swith_body:
 				case "folder":
 					w.WriteLine("COMPREPLY=( $(compgen -d -- \"${COMP_WORDS[COMP_CWORD]}\") )\n")
 				case "function":
-					w.WriteLine(fmt.Sprintf("values=$(%s)\n", arg.Completion.Bash))
+					w.WriteLine(fmt.Sprintf("values=$(%s)\n", functionCode(cliName, arg.Completion, arg.Completion.Bash)))
 					w.WriteLine("COMPREPLY=( $(compgen -W \"${values[*]}\" -- \"${COMP_WORDS[COMP_CWORD]}\") )\n")
 				}
 				if count == 1 {
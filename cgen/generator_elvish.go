@@ -0,0 +1,326 @@
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type elvishGenerator struct{}
+
+func (elvishGenerator) Name() string {
+	return "elvish"
+}
+
+func (elvishGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("elvish", "lib", fmt.Sprintf("%s.elv", cli.Name))
+}
+
+func (elvishGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeElvishCompletions(cli, w)
+}
+
+func GenerateElvishCompletions(cli *CLI) error {
+	return WriteFiles(elvishGenerator{}, cli, "share")
+}
+
+func writeElvishCompletions(cli *CLI, w io.Writer) error {
+	iw := newIndentedWriter(w, "    ")
+
+	if err := writeHeader(w, cli, "#"); err != nil {
+		return err
+	}
+	iw.WriteLine("\n")
+	iw.WriteLine("use path\n")
+	iw.WriteLine("use str\n\n")
+
+	for _, name := range providerNames(cli) {
+		writeElvishFunction(iw, providerFunction(cli.Name, name), cli.Providers[name].Elvish)
+	}
+
+	commands := flattenCommands(cli)
+	for _, c := range commands {
+		// The global options are written with the tool.
+		for _, arg := range slices.Concat(c.options[:len(c.options)-c.globals], c.positionals) {
+			if arg.Completion.Type == "function" && arg.Completion.Provider == "" {
+				writeElvishFunction(iw, elvishCompleter(c.key, arg), arg.Completion.Elvish)
+			}
+		}
+	}
+
+	iw.WriteLine(fmt.Sprintf("set edit:completion:arg-completer[%s] = {|@words|\n", elvishQuote(cli.Name)))
+	err := iw.Indent(func() error {
+		iw.WriteLine("# Options whose value is the next word, by command.\n")
+		iw.WriteLine("var value-options = [\n")
+		err := iw.Indent(func() error {
+			for _, c := range commands {
+				names := []string{}
+				for _, arg := range c.options {
+					spaced, err := spacedOptionNames(arg)
+					if err != nil {
+						return err
+					}
+					names = append(names, spaced...)
+				}
+				if len(names) > 0 {
+					iw.WriteLine(fmt.Sprintf("&%s=[%s]\n", elvishQuote(c.key), elvishList(names)))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		iw.WriteLine("]\n\n")
+
+		iw.WriteLine("# Commands entered by each name and alias, by the command they are in and that name.\n")
+		iw.WriteLine("var subcommands = [\n")
+		iw.Indent(func() error {
+			for _, c := range commands {
+				for _, sub := range c.subcommands {
+					for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+						iw.WriteLine(fmt.Sprintf("&%s=%s\n", elvishQuote(c.key+";"+name), elvishQuote(c.key+";"+sub.Name)))
+					}
+				}
+			}
+			return nil
+		})
+		iw.WriteLine("]\n\n")
+
+		iw.WriteLine("# Follow the commands in the words before the one being completed, counting the positional\n")
+		iw.WriteLine("# arguments and skipping the values of options.\n")
+		iw.WriteLine(fmt.Sprintf("var command = %s\n", elvishQuote(cli.Name)))
+		iw.WriteLine("var positionals = 0\n")
+		iw.WriteLine("var option = ''\n")
+		iw.WriteLine("for word $words[1..-1] {\n")
+		iw.Indent(func() error {
+			iw.WriteLine("if (!=s $option '') {\n")
+			iw.Indent(func() error { return iw.WriteLine("set option = ''\n") })
+			iw.WriteLine("} elif (and (has-key $value-options $command) (has-value $value-options[$command] $word)) {\n")
+			iw.Indent(func() error { return iw.WriteLine("set option = $word\n") })
+			iw.WriteLine("} elif (has-key $subcommands $command';'$word) {\n")
+			iw.Indent(func() error {
+				iw.WriteLine("set command = $subcommands[$command';'$word]\n")
+				iw.WriteLine("set positionals = 0\n")
+				return nil
+			})
+			iw.WriteLine("} elif (not (str:has-prefix $word -)) {\n")
+			iw.Indent(func() error { return iw.WriteLine("set positionals = (+ $positionals 1)\n") })
+			iw.WriteLine("}\n")
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+
+		iw.WriteLine("# The value of an option can also follow it after an equal sign.\n")
+		iw.WriteLine("var word = $words[-1]\n")
+		iw.WriteLine("var prefix = ''\n")
+		iw.WriteLine("if (and (==s $option '') (str:has-prefix $word -) (str:contains $word =)) {\n")
+		iw.Indent(func() error {
+			iw.WriteLine("var i = (str:index $word =)\n")
+			iw.WriteLine("set option = $word[..$i]\n")
+			iw.WriteLine("set prefix = $word[..(+ $i 1)]\n")
+			iw.WriteLine("set word = $word[(+ $i 1)..]\n")
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+
+		for i, c := range commands {
+			keyword := "} elif"
+			if i == 0 {
+				keyword = "if"
+			}
+			iw.WriteLine(fmt.Sprintf("%s (==s $command %s) {\n", keyword, elvishQuote(c.key)))
+			err := iw.Indent(func() error {
+				return writeElvishCommand(iw, cli.Name, c)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		iw.WriteLine("}\n")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	iw.WriteLine("}\n")
+	return nil
+}
+
+// writeElvishFunction writes a function with the given body. The no-op of an empty body, :, is not
+// Elvish, whose functions can be empty.
+func writeElvishFunction(iw *indentedWriter, name, body string) {
+	iw.WriteLine(fmt.Sprintf("fn %s {\n", name))
+	iw.Indent(func() error {
+		if strings.TrimSpace(body) == "" {
+			return nil
+		}
+		for _, line := range functionBody(body) {
+			iw.WriteLine(line + "\n")
+		}
+		return nil
+	})
+	iw.WriteLine("}\n\n")
+}
+
+// elvishCompleter returns the name of the function that runs the function completion of an argument
+// declared by the command at key.
+func elvishCompleter(key string, arg Argument) string {
+	return "__" + shellIdentifier(strings.ReplaceAll(key, ";", "_")) + "_complete_" + shellIdentifier(argumentName(arg))
+}
+
+// writeElvishCommand writes the candidates of a command: the values of the option in $option, the
+// options if the word starts with a dash, and the subcommands and positional arguments otherwise.
+func writeElvishCommand(iw *indentedWriter, cliName string, c flatCommand) error {
+	iw.WriteLine("if (!=s $option '') {\n")
+	err := iw.Indent(func() error {
+		first := true
+		for i, arg := range c.options {
+			if arg.Completion.Type == "none" {
+				continue
+			}
+			names, err := optionNames(arg)
+			if err != nil {
+				return err
+			}
+			keyword := "} elif"
+			if first {
+				keyword = "if"
+			}
+			first = false
+			iw.WriteLine(fmt.Sprintf("%s (has-value [%s] $option) {\n", keyword, elvishList(names)))
+			iw.Indent(func() error {
+				writeElvishValues(iw, cliName, c.optionOwner(i), arg)
+				return nil
+			})
+		}
+		if !first {
+			iw.WriteLine("}\n")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	iw.WriteLine("} elif (str:has-prefix $word -) {\n")
+	err = iw.Indent(func() error {
+		for _, arg := range c.options {
+			names, err := optionNames(arg)
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				if arg.Completion.Type != "none" && arg.LongValueSeparator == "equal" && strings.HasPrefix(name, "--") {
+					name += "="
+				}
+				iw.WriteLine(elvishCandidate(name, arg.ShortDescription, arg.LongDescription) + "\n")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	iw.WriteLine("} else {\n")
+	iw.Indent(func() error {
+		for _, sub := range c.subcommands {
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				iw.WriteLine(elvishCandidate(name, sub.ShortDescription, sub.LongDescription) + "\n")
+			}
+		}
+		first := true
+		for i, arg := range c.positionals {
+			if arg.Completion.Type == "none" {
+				continue
+			}
+			keyword := "} elif"
+			if first {
+				keyword = "if"
+			}
+			first = false
+			iw.WriteLine(fmt.Sprintf("%s (== $positionals %d) {\n", keyword, i))
+			iw.Indent(func() error {
+				writeElvishValues(iw, cliName, c.key, arg)
+				return nil
+			})
+		}
+		if !first {
+			iw.WriteLine("}\n")
+		}
+		return nil
+	})
+	iw.WriteLine("}\n")
+	return nil
+}
+
+// writeElvishValues writes the code that outputs the candidates of the value of an argument declared
+// by the command at key. The value is in $word, after $prefix.
+func writeElvishValues(iw *indentedWriter, cliName, key string, arg Argument) {
+	switch arg.Completion.Type {
+	case "static":
+		iw.WriteLine(fmt.Sprintf("for value [%s] {\n", elvishList(arg.Completion.Values)))
+		iw.Indent(func() error { return iw.WriteLine("put $prefix$value\n") })
+		iw.WriteLine("}\n")
+	case "file":
+		iw.WriteLine("edit:complete-filename $word | each {|c|\n")
+		iw.Indent(func() error {
+			return iw.WriteLine("edit:complex-candidate $prefix$c[stem] &code-suffix=$c[code-suffix]\n")
+		})
+		iw.WriteLine("}\n")
+	case "folder":
+		iw.WriteLine("edit:complete-filename $word | each {|c|\n")
+		iw.Indent(func() error {
+			iw.WriteLine("if (path:is-dir &follow-symlink $c[stem]) {\n")
+			iw.Indent(func() error {
+				return iw.WriteLine("edit:complex-candidate $prefix$c[stem] &code-suffix=$c[code-suffix]\n")
+			})
+			iw.WriteLine("}\n")
+			return nil
+		})
+		iw.WriteLine("}\n")
+	case "function":
+		function := providerFunction(cliName, arg.Completion.Provider)
+		if arg.Completion.Provider == "" {
+			function = elvishCompleter(key, arg)
+		}
+		// The capture takes both the values the function puts and the lines it prints.
+		iw.WriteLine(fmt.Sprintf("for value [(%s)] {\n", function))
+		iw.Indent(func() error { return iw.WriteLine("put $prefix$value\n") })
+		iw.WriteLine("}\n")
+	}
+}
+
+// elvishCandidate returns the command that offers text, showing the first of the descriptions that is
+// not empty next to it. Like the strings put by completers, it is followed by a space, unless it ends
+// with the equal sign of an option.
+func elvishCandidate(text string, descriptions ...string) string {
+	suffix := "' '"
+	if strings.HasSuffix(text, "=") {
+		suffix = "''"
+	}
+	for _, description := range descriptions {
+		description = strings.TrimSpace(strings.SplitN(strings.TrimSpace(description), "\n", 2)[0])
+		if description != "" {
+			return fmt.Sprintf("edit:complex-candidate %s &code-suffix=%s &display=%s", elvishQuote(text), suffix, elvishQuote(text+" ("+description+")"))
+		}
+	}
+	return fmt.Sprintf("edit:complex-candidate %s &code-suffix=%s", elvishQuote(text), suffix)
+}
+
+// elvishQuote returns s as a single-quoted Elvish string.
+func elvishQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// elvishList returns the strings as the items of an Elvish list.
+func elvishList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = elvishQuote(v)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,255 @@
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type emacsGenerator struct{}
+
+func (emacsGenerator) Name() string {
+	return "emacs"
+}
+
+func (emacsGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("emacs", "site-lisp", fmt.Sprintf("%s.el", emacsFeature(cli)))
+}
+
+func (emacsGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeEmacsCompletions(cli, w)
+}
+
+func GenerateEmacsCompletions(cli *CLI) error {
+	return WriteFiles(emacsGenerator{}, cli, "share")
+}
+
+// emacsFeature returns the name of the library, named like the pcomplete libraries of Emacs.
+func emacsFeature(cli *CLI) string {
+	return "pcmpl-" + cli.Name
+}
+
+// lispWriter writes Emacs Lisp, where closing parentheses end the last line instead of starting
+// their own.
+type lispWriter struct {
+	strings.Builder
+}
+
+// line starts a new line, indented by the given number of columns.
+func (l *lispWriter) line(indent int, s string) {
+	l.WriteString("\n" + strings.Repeat(" ", indent) + s)
+}
+
+// lines writes the lines of code, each indented by the given number of columns.
+func (l *lispWriter) lines(indent int, code string) {
+	for _, line := range strings.Split(strings.TrimRight(code, "\n "), "\n") {
+		l.line(indent, line)
+	}
+}
+
+// writeEmacsCompletions writes a pcomplete/<tool> function, which completes the arguments of the tool
+// in eshell and in the other modes that use pcomplete.
+func writeEmacsCompletions(cli *CLI, w io.Writer) error {
+	feature := emacsFeature(cli)
+	if _, err := fmt.Fprintf(w, ";;; %s.el --- Completion of %s  -*- lexical-binding: t -*-\n\n", feature, cli.Name); err != nil {
+		return err
+	}
+	if err := writeHeader(w, cli, ";;"); err != nil {
+		return err
+	}
+
+	var l lispWriter
+	l.line(0, ";;; Code:\n")
+	l.line(0, "(require 'pcomplete)\n")
+
+	for _, name := range providerNames(cli) {
+		l.line(0, fmt.Sprintf("(defun %s ()", providerFunction(cli.Name, name)))
+		l.line(2, elispQuote(fmt.Sprintf("Return the completions of the provider %s.", name)))
+		if code := cli.Providers[name].Elisp; strings.TrimSpace(code) != "" {
+			l.lines(2, code)
+		} else {
+			l.line(2, emacsShellCommand(cli.Providers[name].Bash))
+		}
+		l.WriteString(")\n")
+	}
+
+	commands := flattenCommands(cli)
+	valueOptions := []string{}
+	subcommands := []string{}
+	for _, c := range commands {
+		names := []string{}
+		for _, arg := range c.options {
+			spaced, err := spacedOptionNames(arg)
+			if err != nil {
+				return err
+			}
+			names = append(names, spaced...)
+		}
+		if len(names) > 0 {
+			valueOptions = append(valueOptions, fmt.Sprintf("(%s)", elispList(append([]string{c.key}, names...))))
+		}
+		for _, sub := range c.subcommands {
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				subcommands = append(subcommands, fmt.Sprintf("(%s . %s)", elispQuote(c.key+";"+name), elispQuote(c.key+";"+sub.Name)))
+			}
+		}
+	}
+
+	l.line(0, fmt.Sprintf("(defun pcomplete/%s ()", cli.Name))
+	l.line(2, elispQuote(fmt.Sprintf("Complete the arguments of %s.", cli.Name)))
+	l.line(2, "(let (;; Options whose value is the next word, by command.")
+	l.line(8, "(value-options '("+strings.Join(valueOptions, "\n"+strings.Repeat(" ", 25))+"))")
+	l.line(8, ";; Commands entered by each name and alias, by the command they are in and that name.")
+	l.line(8, "(subcommands '("+strings.Join(subcommands, "\n"+strings.Repeat(" ", 23))+"))")
+	l.line(8, fmt.Sprintf("(command %s)", elispQuote(cli.Name)))
+	l.line(8, "(positionals 0)")
+	l.line(8, "(option nil))")
+	l.line(4, "(while t")
+	l.line(6, "(let ((word (pcomplete-arg)))")
+	l.line(8, "(if (< pcomplete-index pcomplete-last)")
+	l.line(12, ";; Follow the commands in the words before the one being completed, counting the")
+	l.line(12, ";; positional arguments and skipping the values of options.")
+	l.line(12, "(progn")
+	l.line(14, "(cond")
+	l.line(15, "(option")
+	l.line(16, "(setq option nil))")
+	l.line(15, "((member word (cdr (assoc command value-options)))")
+	l.line(16, "(setq option word))")
+	l.line(15, `((assoc (concat command ";" word) subcommands)`)
+	l.line(16, `(setq command (cdr (assoc (concat command ";" word) subcommands))`)
+	l.line(22, "positionals 0))")
+	l.line(15, `((not (string-prefix-p "-" word))`)
+	l.line(16, "(setq positionals (1+ positionals))))")
+	l.line(14, "(pcomplete-next-arg))")
+	l.line(10, ";; The value of an option can also follow it after an equal sign.")
+	l.line(10, "(progn")
+	l.line(12, "(when (and (not option) (string-match \"\\\\`\\\\(-[^=]+\\\\)=\" word))")
+	l.line(14, "(setq option (match-string 1 word)")
+	l.line(20, "pcomplete-stub (substring word (match-end 0))))")
+	l.line(12, "(pcomplete-here")
+	l.line(13, "(pcase command")
+	for _, c := range commands {
+		if err := writeEmacsCommand(&l, cli, c); err != nil {
+			return err
+		}
+	}
+	l.WriteString(")")
+	l.line(13, "pcomplete-stub))))))))\n")
+
+	l.line(0, fmt.Sprintf("(provide '%s)\n", feature))
+	l.line(0, fmt.Sprintf(";;; %s.el ends here\n", feature))
+
+	_, err := io.WriteString(w, l.String())
+	return err
+}
+
+// writeEmacsCommand writes the pcase branch of the candidates of a command: the values of the option
+// in option, the options if the word starts with a dash, and the subcommands and positional arguments
+// otherwise.
+func writeEmacsCommand(l *lispWriter, cli *CLI, c flatCommand) error {
+	l.line(15, fmt.Sprintf("(%s", elispQuote(c.key)))
+	l.line(16, "(cond")
+
+	l.line(17, "(option")
+	l.line(18, "(pcase option")
+	for _, arg := range c.options {
+		if arg.Completion.Type == "none" {
+			continue
+		}
+		names, err := optionNames(arg)
+		if err != nil {
+			return err
+		}
+		pattern := elispQuote(names[0])
+		if len(names) > 1 {
+			pattern = "(or " + elispList(names) + ")"
+		}
+		l.line(20, fmt.Sprintf("(%s", pattern))
+		l.line(21, emacsValues(cli, arg)+")")
+	}
+	l.WriteString("))")
+
+	options := []string{}
+	for _, arg := range c.options {
+		names, err := optionNames(arg)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if arg.Completion.Type != "none" && arg.LongValueSeparator == "equal" && strings.HasPrefix(name, "--") {
+				name += "="
+			}
+			options = append(options, name)
+		}
+	}
+	l.line(17, `((string-prefix-p "-" word)`)
+	l.line(18, fmt.Sprintf("'(%s))", elispList(options)))
+
+	positionals := ""
+	if slices.ContainsFunc(c.positionals, func(arg Argument) bool { return arg.Completion.Type != "none" }) {
+		positionals = "(pcase positionals"
+		for i, arg := range c.positionals {
+			if arg.Completion.Type != "none" {
+				positionals += fmt.Sprintf(" (%d %s)", i, emacsValues(cli, arg))
+			}
+		}
+		positionals += ")"
+	}
+	candidates := "nil"
+	switch names := commandNames(c.subcommands); {
+	case len(names) > 0 && positionals != "":
+		candidates = fmt.Sprintf("(completion-table-merge '(%s) %s)", elispList(names), positionals)
+	case len(names) > 0:
+		candidates = fmt.Sprintf("'(%s)", elispList(names))
+	case positionals != "":
+		candidates = positionals
+	}
+	l.line(17, "(t")
+	l.line(18, fmt.Sprintf("%s)))", candidates))
+	return nil
+}
+
+// emacsValues returns the form that evaluates to the completions of the value of an argument. Without
+// Emacs Lisp code, function completions run their Bash code in the shell.
+func emacsValues(cli *CLI, arg Argument) string {
+	switch arg.Completion.Type {
+	case "static":
+		return fmt.Sprintf("'(%s)", elispList(arg.Completion.Values))
+	case "file":
+		return "(pcomplete-entries)"
+	case "folder":
+		return "(pcomplete-dirs)"
+	case "function":
+		if arg.Completion.Provider != "" {
+			return fmt.Sprintf("(%s)", providerFunction(cli.Name, arg.Completion.Provider))
+		}
+		if code := strings.TrimSpace(arg.Completion.Elisp); code != "" {
+			return fmt.Sprintf("(progn %s)", code)
+		}
+		if strings.TrimSpace(arg.Completion.Bash) != "" {
+			return emacsShellCommand(arg.Completion.Bash)
+		}
+	}
+	return "nil"
+}
+
+// emacsShellCommand returns the form that runs code in the shell and returns the lines it prints.
+func emacsShellCommand(code string) string {
+	return fmt.Sprintf(`(split-string (shell-command-to-string %s) "\n" t)`, elispQuote(strings.TrimRight(code, "\n ")))
+}
+
+// elispQuote returns s as an Emacs Lisp string.
+func elispQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// elispList returns the strings as the items of an Emacs Lisp list.
+func elispList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = elispQuote(v)
+	}
+	return strings.Join(quoted, " ")
+}
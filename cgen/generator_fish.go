@@ -3,30 +3,44 @@ package cgen
 import (
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"al.essio.dev/pkg/shellescape"
 )
 
-func GenerateFishCompletions(cli *CLI) error {
-	dir := filepath.Join("share", "fish", "completions")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("could not create directory: %w", err)
-	}
+type fishGenerator struct{}
 
-	path := filepath.Join(dir, fmt.Sprintf("%s.fish", cli.Name))
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("could not create file: %w", err)
-	}
-	defer file.Close()
+func (fishGenerator) Name() string {
+	return "fish"
+}
+
+func (fishGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("fish", "completions", fmt.Sprintf("%s.fish", cli.Name))
+}
+
+func (fishGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeFishCompletions(cli, w)
+}
 
-	return writeFishCompletions(cli, file)
+func GenerateFishCompletions(cli *CLI) error {
+	return WriteFiles(fishGenerator{}, cli, "share")
 }
 
 func writeFishCompletions(cli *CLI, w io.Writer) error {
+	if err := writeHeader(w, cli, "#"); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	for _, name := range providerNames(cli) {
+		fmt.Fprintf(w, "function %s\n", providerFunction(cli.Name, name))
+		for _, line := range functionBody(cli.Providers[name].Fish) {
+			fmt.Fprintln(w, strings.TrimRight("    "+line, " "))
+		}
+		fmt.Fprint(w, "end\n\n")
+	}
+
 	for _, arg := range cli.Arguments {
 		if _, err := fmt.Fprint(w, formatArgumentFish(cli.Name, arg, "")); err != nil {
 			return err
@@ -95,7 +109,7 @@ func formatArgumentFish(cliName string, arg Argument, condition string) string {
 		values := shellescape.Quote(strings.Join(arg.Completion.Values, " "))
 		b.WriteString(fmt.Sprintf(" -fa %s", values))
 	case "function":
-		b.WriteString(fmt.Sprintf(" -fa %s", shellescape.Quote("("+arg.Completion.Fish+")")))
+		b.WriteString(fmt.Sprintf(" -fa %s", shellescape.Quote("("+functionCode(cliName, arg.Completion, arg.Completion.Fish)+")")))
 	}
 
 	b.WriteString("\n")
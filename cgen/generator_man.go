@@ -3,56 +3,65 @@ package cgen
 import (
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
-	"time"
 )
 
-func GenerateManPage(cli *CLI) error {
-	dir := filepath.Join("share", "man", "man1")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("could not create directory: %w", err)
-	}
-	path := filepath.Join(dir, fmt.Sprintf("%s.1", cli.Name))
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("could not create file: %w", err)
-	}
-	defer file.Close()
-	if err := writeManPage(cli, nil, cli.Arguments, cli.Commands, []string{cli.Name}, file); err != nil {
-		return err
-	}
+type manGenerator struct{}
+
+func (manGenerator) Name() string {
+	return "man"
+}
+
+func (manGenerator) DefaultPath(cli *CLI) string {
+	return manPagePath([]string{cli.Name})
+}
+
+func (manGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeManPage(cli, nil, cli.Arguments, cli.Commands, []string{cli.Name}, w)
+}
+
+func (g manGenerator) Files(cli *CLI) []File {
+	files := []File{{
+		Path:  g.DefaultPath(cli),
+		Write: func(w io.Writer) error { return g.Write(cli, w) },
+	}}
 	for _, cmd := range cli.Commands {
-		if err := generateManPageForCommand(cli, &cmd, append(cli.Arguments, cmd.Arguments...), cmd.Subcommands, []string{cli.Name, cmd.Name}); err != nil {
-			return err
-		}
+		files = append(files, commandManPages(cli, &cmd, []string{cli.Name, cmd.Name})...)
 	}
-	return nil
+	return files
 }
 
-func generateManPageForCommand(cli *CLI, cmd *Command, args []Argument, cmds []Command, parents []string) error {
-	path := filepath.Join("share", "man", "man1", fmt.Sprintf("%s.1", strings.Join(parents, "-")))
-	file, err := os.Create(path)
+func GenerateManPage(cli *CLI) error {
+	return WriteFiles(manGenerator{}, cli, "share")
+}
+
+func manPagePath(parents []string) string {
+	return filepath.Join("man", "man1", fmt.Sprintf("%s.1", strings.Join(parents, "-")))
+}
+
+func commandManPages(cli *CLI, cmd *Command, parents []string) []File {
+	args := slices.Concat(cli.Arguments, cmd.Arguments)
+	files := []File{{
+		Path:  manPagePath(parents),
+		Write: func(w io.Writer) error { return writeManPage(cli, cmd, args, cmd.Subcommands, parents, w) },
+	}}
+	for _, subcmd := range cmd.Subcommands {
+		files = append(files, commandManPages(cli, &subcmd, slices.Concat(parents, []string{subcmd.Name}))...)
+	}
+	return files
+}
+
+func writeManPage(cli *CLI, cmd *Command, args []Argument, cmds []Command, parents []string, file io.Writer) error {
+	date, err := BuildDate(cli)
 	if err != nil {
-		return fmt.Errorf("could not create file: %w", err)
-	}
-	defer file.Close()
-	if err := writeManPage(cli, cmd, args, cmds, parents, file); err != nil {
 		return err
 	}
-	if cmd != nil {
-		for _, subcmd := range cmd.Subcommands {
-			if err := generateManPageForCommand(cli, &subcmd, append(cli.Arguments, subcmd.Arguments...), subcmd.Subcommands, append(parents, subcmd.Name)); err != nil {
-				return err
-			}
-		}
+	if err := writeHeader(file, cli, ".\\\""); err != nil {
+		return err
 	}
-	return nil
-}
-
-func writeManPage(cli *CLI, cmd *Command, args []Argument, cmds []Command, parents []string, file io.Writer) error {
-	fmt.Fprintf(file, ".TH %s 1 \"%s\" \"%s\"\n", strings.Join(parents, "-"), time.Now().Format("02-Jan-2006"), cli.Version)
+	fmt.Fprintf(file, ".TH %s 1 \"%s\" \"%s\"\n", strings.Join(parents, "-"), date, cli.Version)
 	fmt.Fprint(file, ".SH NAME\n")
 	if cmd == nil {
 		fmt.Fprintf(file, "%s \\- %s\n", cli.Name, cli.ShortDescription)
@@ -0,0 +1,233 @@
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+type nushellGenerator struct{}
+
+func (nushellGenerator) Name() string {
+	return "nushell"
+}
+
+func (nushellGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("nushell", "completions", fmt.Sprintf("%s.nu", cli.Name))
+}
+
+func (nushellGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeNushellCompletions(cli, w)
+}
+
+func GenerateNushellCompletions(cli *CLI) error {
+	return WriteFiles(nushellGenerator{}, cli, "share")
+}
+
+func writeNushellCompletions(cli *CLI, w io.Writer) error {
+	iw := newIndentedWriter(w, "    ")
+
+	if err := writeHeader(w, cli, "#"); err != nil {
+		return err
+	}
+	iw.WriteLine("\n")
+
+	for _, name := range providerNames(cli) {
+		iw.WriteLine(fmt.Sprintf("def %s [] {\n", providerFunction(cli.Name, name)))
+		iw.Indent(func() error {
+			// The no-op of an empty body, :, is not Nushell, whose commands can be empty.
+			body := cli.Providers[name].Nushell
+			if strings.TrimSpace(body) == "" {
+				return nil
+			}
+			for _, line := range functionBody(body) {
+				iw.WriteLine(line + "\n")
+			}
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+	}
+
+	writeNushellCompleters(iw, []string{cli.Name}, cli.Arguments)
+	var writeCompleters func(path []string, cmds []Command)
+	writeCompleters = func(path []string, cmds []Command) {
+		for _, cmd := range cmds {
+			cmdPath := slices.Concat(path, []string{cmd.Name})
+			writeNushellCompleters(iw, cmdPath, cmd.Arguments)
+			writeCompleters(cmdPath, cmd.Subcommands)
+		}
+	}
+	writeCompleters([]string{cli.Name}, cli.Commands)
+
+	globals := namedArguments(cli.Arguments)
+	writeNushellExtern(iw, cli.Name, []string{cli.Name}, cli.Name, cli.ShortDescription, cli.LongDescription, cli.Arguments, nil)
+	writeNushellCommands(iw, cli.Name, []string{cli.Name}, cli.Commands, globals)
+	return nil
+}
+
+// writeNushellCommands writes the externs of cmds and of their subcommands, path being the names of
+// the parent command. A command has an extern for its name and for each of its aliases, but they
+// follow the names of its parents only: expanding the aliases of every parent too would multiply the
+// externs at each level.
+func writeNushellCommands(iw *indentedWriter, cliName string, path []string, cmds []Command, globals []Argument) {
+	for _, cmd := range cmds {
+		cmdPath := slices.Concat(path, []string{cmd.Name})
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			writeNushellExtern(iw, cliName, cmdPath, strings.Join(path, " ")+" "+name, cmd.ShortDescription, cmd.LongDescription, cmd.Arguments, globals)
+		}
+		writeNushellCommands(iw, cliName, cmdPath, cmd.Subcommands, globals)
+	}
+}
+
+// writeNushellCompleters writes the commands that complete the static and function values of the
+// arguments of the command at path, except for the ones with a provider.
+func writeNushellCompleters(iw *indentedWriter, path []string, args []Argument) {
+	for _, arg := range args {
+		switch {
+		case arg.Completion.Type == "static":
+			iw.WriteLine(fmt.Sprintf("def %s [] {\n", nushellQuote(nushellCompleter(path, arg))))
+			iw.Indent(func() error {
+				return iw.WriteLine(fmt.Sprintf("[%s]\n", nushellList(arg.Completion.Values)))
+			})
+			iw.WriteLine("}\n\n")
+		case arg.Completion.Type == "function" && arg.Completion.Provider == "":
+			iw.WriteLine(fmt.Sprintf("def %s [] {\n", nushellQuote(nushellCompleter(path, arg))))
+			iw.Indent(func() error {
+				if strings.TrimSpace(arg.Completion.Nushell) == "" {
+					return nil
+				}
+				for _, line := range functionBody(arg.Completion.Nushell) {
+					iw.WriteLine(line + "\n")
+				}
+				return nil
+			})
+			iw.WriteLine("}\n\n")
+		}
+	}
+}
+
+// nushellCompleter returns the name of the command that completes the value of an argument of the
+// command at path.
+func nushellCompleter(path []string, arg Argument) string {
+	return fmt.Sprintf("nu-complete %s %s", strings.Join(path, " "), argumentName(arg))
+}
+
+// writeNushellExtern writes the signature of the command at path, called as name. The global options
+// that the command does not shadow are added to its own.
+func writeNushellExtern(iw *indentedWriter, cliName string, path []string, name, short, long string, args, globals []Argument) {
+	description := short
+	if strings.TrimSpace(description) == "" {
+		description = long
+	}
+	if strings.TrimSpace(description) != "" {
+		for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+			iw.WriteLine(strings.TrimRight("# "+line, " ") + "\n")
+		}
+	}
+
+	options := []string{}
+	for _, arg := range namedArguments(args) {
+		if param := nushellOption(cliName, path, arg); param != "" {
+			options = append(options, param+nushellComment(arg))
+		}
+	}
+	for _, global := range globals {
+		shadowed := slices.ContainsFunc(args, func(arg Argument) bool {
+			return arg.Named && (global.Name != "" && arg.Name == global.Name || global.ShortName != "" && arg.ShortName == global.ShortName)
+		})
+		// Globals are completed by the completers of the tool.
+		if param := nushellOption(cliName, path[:1], global); param != "" && !shadowed {
+			options = append(options, param+nushellComment(global))
+		}
+	}
+
+	iw.WriteLine(fmt.Sprintf("export extern %s [\n", nushellQuote(name)))
+	iw.Indent(func() error {
+		for _, option := range options {
+			iw.WriteLine(option + "\n")
+		}
+		for i, arg := range collectPositionalArgs(args) {
+			name := shellIdentifier(argumentName(arg))
+			if name == "" {
+				name = fmt.Sprintf("arg%d", i+1)
+			}
+			param := fmt.Sprintf("%s?: %s", name, nushellType(cliName, path, arg))
+			iw.WriteLine(param + nushellComment(arg) + "\n")
+		}
+		return nil
+	})
+	iw.WriteLine("]\n\n")
+}
+
+// nushellOption returns the flag of a named argument, or an empty string if Nushell cannot express it:
+// its long options have two dashes and its short ones a single character.
+func nushellOption(cliName string, path []string, arg Argument) string {
+	short := ""
+	if utf8.RuneCountInString(arg.ShortName) == 1 {
+		short = "-" + arg.ShortName
+	}
+	flag := short
+	if arg.Name != "" && !arg.SingleDashLong {
+		flag = "--" + arg.Name
+		if short != "" {
+			flag += "(" + short + ")"
+		}
+	}
+	if flag == "" {
+		return ""
+	}
+	if arg.Completion.Type != "none" {
+		flag += ": " + nushellType(cliName, path, arg)
+	}
+	return flag
+}
+
+// nushellType returns the type of the value of an argument of the command at path, with its
+// completer.
+func nushellType(cliName string, path []string, arg Argument) string {
+	switch arg.Completion.Type {
+	case "file":
+		return "path"
+	case "folder":
+		return "directory"
+	case "static":
+		return "string@" + nushellQuote(nushellCompleter(path, arg))
+	case "function":
+		if arg.Completion.Provider != "" {
+			return "string@" + providerFunction(cliName, arg.Completion.Provider)
+		}
+		return "string@" + nushellQuote(nushellCompleter(path, arg))
+	}
+	return "string"
+}
+
+// nushellComment returns the comment that describes a parameter, which Nushell shows next to its
+// completions, from the first line of the description of the argument.
+func nushellComment(arg Argument) string {
+	description := arg.ShortDescription
+	if strings.TrimSpace(description) == "" {
+		description = arg.LongDescription
+	}
+	description = strings.TrimSpace(strings.SplitN(strings.TrimSpace(description), "\n", 2)[0])
+	if description == "" {
+		return ""
+	}
+	return " # " + description
+}
+
+// nushellQuote returns s as a double-quoted Nushell string.
+func nushellQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(s) + `"`
+}
+
+// nushellList returns the strings as the items of a Nushell list.
+func nushellList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = nushellQuote(v)
+	}
+	return strings.Join(quoted, " ")
+}
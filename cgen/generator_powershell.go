@@ -0,0 +1,393 @@
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type powershellGenerator struct{}
+
+func (powershellGenerator) Name() string {
+	return "powershell"
+}
+
+func (powershellGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("powershell", "completions", fmt.Sprintf("%s.ps1", cli.Name))
+}
+
+func (powershellGenerator) Write(cli *CLI, w io.Writer) error {
+	return writePowershellCompletions(cli, w)
+}
+
+func GeneratePowershellCompletions(cli *CLI) error {
+	return WriteFiles(powershellGenerator{}, cli, "share")
+}
+
+func writePowershellCompletions(cli *CLI, w io.Writer) error {
+	iw := newIndentedWriter(w, "    ")
+
+	if err := writeHeader(w, cli, "#"); err != nil {
+		return err
+	}
+	iw.WriteLine("\n")
+	iw.WriteLine("using namespace System.Management.Automation\n\n")
+
+	for _, name := range providerNames(cli) {
+		iw.WriteLine(fmt.Sprintf("function %s {\n", providerFunction(cli.Name, name)))
+		iw.Indent(func() error {
+			// The no-op of an empty body, :, is not PowerShell, whose functions can be empty.
+			body := cli.Providers[name].PowerShell
+			if strings.TrimSpace(body) == "" {
+				return nil
+			}
+			for _, line := range functionBody(body) {
+				iw.WriteLine(line + "\n")
+			}
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+	}
+
+	commands := flattenCommands(cli)
+
+	iw.WriteLine(fmt.Sprintf("Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {\n", powershellQuote(cli.Name)))
+	err := iw.Indent(func() error {
+		iw.WriteLine("param($wordToComplete, $commandAst, $cursorPosition)\n\n")
+
+		iw.WriteLine("# Quotes the values that PowerShell would split or interpret.\n")
+		iw.WriteLine("function Quote($value) {\n")
+		iw.Indent(func() error {
+			iw.WriteLine("if ($value -match '[\\s''\"`$&|;,(){}@#<>]') { \"'\" + ($value -replace \"'\", \"''\") + \"'\" } else { $value }\n")
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+
+		attached := []string{}
+		iw.WriteLine("# Options whose value is the next word, by command.\n")
+		iw.WriteLine("$valueOptions = @{\n")
+		err := iw.Indent(func() error {
+			for _, c := range commands {
+				names, short := []string{}, []string{}
+				for _, arg := range c.options {
+					spaced, err := spacedOptionNames(arg)
+					if err != nil {
+						return err
+					}
+					names = append(names, spaced...)
+					short = append(short, attachedOptionNames(arg)...)
+				}
+				if len(names) > 0 {
+					iw.WriteLine(fmt.Sprintf("%s = @(%s)\n", powershellQuote(c.key), powershellList(names)))
+				}
+				if len(short) > 0 {
+					attached = append(attached, fmt.Sprintf("%s = @(%s)\n", powershellQuote(c.key), powershellList(short)))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		iw.WriteLine("}\n\n")
+
+		if len(attached) > 0 {
+			iw.WriteLine("# Short options whose value can be attached to them, like -ofile, by command.\n")
+			iw.WriteLine("$attachedOptions = @{\n")
+			iw.Indent(func() error {
+				for _, line := range attached {
+					iw.WriteLine(line)
+				}
+				return nil
+			})
+			iw.WriteLine("}\n\n")
+		}
+
+		iw.WriteLine("# Follow the commands in the words before the one being completed, counting the positional\n")
+		iw.WriteLine("# arguments and skipping the values of options.\n")
+		iw.WriteLine(fmt.Sprintf("$command = %s\n", powershellQuote(cli.Name)))
+		iw.WriteLine("$positionals = 0\n")
+		iw.WriteLine("$option = ''\n")
+		iw.WriteLine("foreach ($element in $commandAst.CommandElements | Select-Object -Skip 1) {\n")
+		iw.Indent(func() error {
+			iw.WriteLine("if ($element.Extent.EndOffset -ge $cursorPosition) {\n")
+			iw.Indent(func() error { return iw.WriteLine("break\n") })
+			iw.WriteLine("}\n")
+			iw.WriteLine("$word = $element.Extent.Text\n")
+			iw.WriteLine("if ($option) {\n")
+			iw.Indent(func() error { return iw.WriteLine("$option = ''\n") })
+			iw.WriteLine("} elseif ($word -cin $valueOptions[$command]) {\n")
+			iw.Indent(func() error { return iw.WriteLine("$option = $word\n") })
+			iw.WriteLine("} elseif (-not $word.StartsWith('-')) {\n")
+			iw.Indent(func() error {
+				writePowershellSubcommandSwitch(iw, commands)
+				return nil
+			})
+			iw.WriteLine("}\n")
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+
+		if len(attached) > 0 {
+			iw.WriteLine("# The value of an option can also follow it after an equal sign, or be attached to a short option.\n")
+		} else {
+			iw.WriteLine("# The value of an option can also follow it after an equal sign.\n")
+		}
+		iw.WriteLine("$prefix = ''\n")
+		iw.WriteLine("$word = $wordToComplete\n")
+		iw.WriteLine("if (-not $option -and $wordToComplete -match '^(-[^=]+)=(.*)$') {\n")
+		iw.Indent(func() error {
+			iw.WriteLine("$option = $Matches[1]\n")
+			iw.WriteLine("$prefix = $option + '='\n")
+			iw.WriteLine("$word = $Matches[2]\n")
+			return nil
+		})
+		if len(attached) > 0 {
+			iw.WriteLine("} elseif (-not $option) {\n")
+			iw.Indent(func() error {
+				iw.WriteLine("foreach ($name in $attachedOptions[$command]) {\n")
+				iw.Indent(func() error {
+					iw.WriteLine("if ($wordToComplete.StartsWith($name, [StringComparison]::Ordinal)) {\n")
+					iw.Indent(func() error {
+						iw.WriteLine("$option = $name\n")
+						iw.WriteLine("$prefix = $name\n")
+						iw.WriteLine("$word = $wordToComplete.Substring($name.Length)\n")
+						iw.WriteLine("break\n")
+						return nil
+					})
+					iw.WriteLine("}\n")
+					return nil
+				})
+				iw.WriteLine("}\n")
+				return nil
+			})
+		}
+		iw.WriteLine("}\n\n")
+
+		iw.WriteLine("$completions = switch ($command) {\n")
+		err = iw.Indent(func() error {
+			for _, c := range commands {
+				if err := writePowershellCommand(iw, cli.Name, c); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		iw.WriteLine("}\n")
+		iw.WriteLine("# Paths are completed by PowerShell, which already matched them and may have changed the word.\n")
+		iw.WriteLine("$completions | Where-Object { $_.ResultType -in 'ProviderItem', 'ProviderContainer' -or $_.CompletionText.StartsWith($wordToComplete) }\n")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	iw.WriteLine("}\n")
+	return nil
+}
+
+// writePowershellSubcommandSwitch writes the code that enters the subcommand named by $word, or
+// counts it as a positional argument.
+func writePowershellSubcommandSwitch(iw *indentedWriter, commands []flatCommand) {
+	cases := [][2]string{}
+	for _, c := range commands {
+		for _, sub := range c.subcommands {
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				cases = append(cases, [2]string{c.key + ";" + name, c.key + ";" + sub.Name})
+			}
+		}
+	}
+	if len(cases) == 0 {
+		iw.WriteLine("$positionals++\n")
+		return
+	}
+
+	iw.WriteLine("$subcommand = switch -CaseSensitive (\"$command;$word\") {\n")
+	iw.Indent(func() error {
+		for _, c := range cases {
+			iw.WriteLine(fmt.Sprintf("%s { %s }\n", powershellQuote(c[0]), powershellQuote(c[1])))
+		}
+		return nil
+	})
+	iw.WriteLine("}\n")
+	iw.WriteLine("if ($subcommand) {\n")
+	iw.Indent(func() error {
+		iw.WriteLine("$command = $subcommand\n")
+		iw.WriteLine("$positionals = 0\n")
+		return nil
+	})
+	iw.WriteLine("} else {\n")
+	iw.Indent(func() error { return iw.WriteLine("$positionals++\n") })
+	iw.WriteLine("}\n")
+}
+
+// writePowershellCommand writes the completions of a command: the values of the option in $option,
+// the options if the word starts with a dash, and the subcommands and positional arguments otherwise.
+func writePowershellCommand(iw *indentedWriter, cliName string, c flatCommand) error {
+	iw.WriteLine(fmt.Sprintf("%s {\n", powershellQuote(c.key)))
+	err := iw.Indent(func() error {
+		valued := []Argument{}
+		for _, arg := range c.options {
+			if arg.Completion.Type != "none" {
+				valued = append(valued, arg)
+			}
+		}
+
+		iw.WriteLine("if ($option) {\n")
+		err := iw.Indent(func() error {
+			// PowerShell does not accept a switch without cases.
+			if len(valued) == 0 {
+				return nil
+			}
+			iw.WriteLine("switch -CaseSensitive ($option) {\n")
+			err := iw.Indent(func() error {
+				for _, arg := range valued {
+					names, err := optionNames(arg)
+					if err != nil {
+						return err
+					}
+					iw.WriteLine(fmt.Sprintf("{ $_ -cin %s } {\n", powershellList(names)))
+					iw.Indent(func() error {
+						writePowershellValues(iw, cliName, arg)
+						return nil
+					})
+					iw.WriteLine("}\n")
+				}
+				return nil
+			})
+			iw.WriteLine("}\n")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		iw.WriteLine("} elseif ($word.StartsWith('-')) {\n")
+		err = iw.Indent(func() error {
+			for _, arg := range c.options {
+				names, err := optionNames(arg)
+				if err != nil {
+					return err
+				}
+				tooltip := powershellTooltip(arg.ShortDescription, arg.LongDescription, argumentName(arg))
+				for _, name := range names {
+					if arg.Completion.Type != "none" && arg.LongValueSeparator == "equal" && strings.HasPrefix(name, "--") {
+						name += "="
+					}
+					iw.WriteLine(fmt.Sprintf("[CompletionResult]::new(%s, %s, 'ParameterName', %s)\n", powershellQuote(name), powershellQuote(name), tooltip))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		iw.WriteLine("} else {\n")
+		iw.Indent(func() error {
+			for _, sub := range c.subcommands {
+				tooltip := powershellTooltip(sub.ShortDescription, sub.LongDescription, sub.Name)
+				for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+					iw.WriteLine(fmt.Sprintf("[CompletionResult]::new(%s, %s, 'ParameterValue', %s)\n", powershellQuote(name), powershellQuote(name), tooltip))
+				}
+			}
+			if slices.ContainsFunc(c.positionals, func(arg Argument) bool { return arg.Completion.Type != "none" }) {
+				iw.WriteLine("switch ($positionals) {\n")
+				iw.Indent(func() error {
+					for i, arg := range c.positionals {
+						if arg.Completion.Type == "none" {
+							continue
+						}
+						iw.WriteLine(fmt.Sprintf("%d {\n", i))
+						iw.Indent(func() error {
+							writePowershellValues(iw, cliName, arg)
+							return nil
+						})
+						iw.WriteLine("}\n")
+					}
+					return nil
+				})
+				iw.WriteLine("}\n")
+			}
+			return nil
+		})
+		iw.WriteLine("}\n")
+		return nil
+	})
+	iw.WriteLine("}\n")
+	return err
+}
+
+// writePowershellValues writes the code that outputs the completions of the value of an argument,
+// which is in $word, after $prefix.
+func writePowershellValues(iw *indentedWriter, cliName string, arg Argument) {
+	result := func(source string) {
+		iw.Indent(func() error {
+			return iw.WriteLine(fmt.Sprintf("[CompletionResult]::new($prefix + (Quote %s), %s, 'ParameterValue', %s)\n", source, source, source))
+		})
+	}
+
+	switch arg.Completion.Type {
+	case "static":
+		iw.WriteLine(fmt.Sprintf("foreach ($value in @(%s)) {\n", powershellList(arg.Completion.Values)))
+		result("$value")
+		iw.WriteLine("}\n")
+	case "file", "folder":
+		filter := ""
+		if arg.Completion.Type == "folder" {
+			filter = " | Where-Object ResultType -eq 'ProviderContainer'"
+		}
+		iw.WriteLine(fmt.Sprintf("[CompletionCompleters]::CompleteFilename($word)%s | ForEach-Object {\n", filter))
+		iw.Indent(func() error {
+			return iw.WriteLine("[CompletionResult]::new($prefix + $_.CompletionText, $_.ListItemText, $_.ResultType, $_.ToolTip)\n")
+		})
+		iw.WriteLine("}\n")
+	case "function":
+		if arg.Completion.Provider != "" {
+			iw.WriteLine(fmt.Sprintf("%s | Where-Object { \"$_\" } | ForEach-Object {\n", providerFunction(cliName, arg.Completion.Provider)))
+		} else if strings.TrimSpace(arg.Completion.PowerShell) == "" {
+			return
+		} else {
+			iw.WriteLine("& {\n")
+			iw.Indent(func() error {
+				for _, line := range functionBody(arg.Completion.PowerShell) {
+					iw.WriteLine(line + "\n")
+				}
+				return nil
+			})
+			iw.WriteLine("} | Where-Object { \"$_\" } | ForEach-Object {\n")
+		}
+		result(`"$_"`)
+		iw.WriteLine("}\n")
+	}
+}
+
+// powershellTooltip returns the quoted tooltip of a completion: the first of the texts that is not
+// empty, as tooltips cannot be empty.
+func powershellTooltip(texts ...string) string {
+	for _, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			return powershellQuote(text)
+		}
+	}
+	return "' '"
+}
+
+// powershellQuote returns s as a single-quoted PowerShell string. PowerShell also takes the
+// typographic single quotes as quotes, so they are doubled too.
+func powershellQuote(s string) string {
+	return "'" + strings.NewReplacer("'", "''", "‘", "‘‘", "’", "’’", "‚", "‚‚", "‛", "‛‛").Replace(s) + "'"
+}
+
+// powershellList returns the strings as a comma-separated list of PowerShell strings.
+func powershellList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = powershellQuote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
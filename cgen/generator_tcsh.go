@@ -0,0 +1,217 @@
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type tcshGenerator struct{}
+
+func (tcshGenerator) Name() string {
+	return "tcsh"
+}
+
+func (tcshGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("tcsh", "completions", fmt.Sprintf("%s.tcsh", cli.Name))
+}
+
+func (tcshGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeTcshCompletions(cli, w)
+}
+
+func GenerateTcshCompletions(cli *CLI) error {
+	return WriteFiles(tcshGenerator{}, cli, "share")
+}
+
+// writeTcshCompletions writes a complete command for the tool. Its rules only see the word being
+// completed and the one before it, and the first rule that matches is used, so:
+//   - the options of every command are offered everywhere, and the first option with a name decides
+//     how its value is completed;
+//   - subcommands are completed right after the name or alias of their parent, and the first
+//     positional argument of a command without subcommands right after its name;
+//   - the other positional arguments of commands, and those of a tool with commands, are not
+//     completed, and the positions of those of a tool without commands count its options too;
+//   - values attached to short options, like -ofile, are not completed;
+//   - static values and completion words cannot contain spaces;
+//   - descriptions are not shown.
+func writeTcshCompletions(cli *CLI, w io.Writer) error {
+	iw := newIndentedWriter(w, "    ")
+
+	if err := writeHeader(w, cli, "#"); err != nil {
+		return err
+	}
+	iw.WriteLine("\n")
+
+	// tcsh has no functions: providers are aliases, which backquoted commands can run.
+	for _, name := range providerNames(cli) {
+		iw.WriteLine(fmt.Sprintf("alias %s %s\n", providerFunction(cli.Name, name), tcshQuote(tcshCommand(cli.Providers[name].Tcsh))))
+	}
+	if len(cli.Providers) > 0 {
+		iw.WriteLine("\n")
+	}
+
+	rules := []string{}
+	add := func(rule string) {
+		if !slices.Contains(rules, rule) {
+			rules = append(rules, rule)
+		}
+	}
+
+	commands := flattenCommands(cli)
+	options := []Argument{}
+	for _, c := range commands {
+		options = append(options, c.options...)
+	}
+
+	// Values after an equal sign, and after the option, in a separate word.
+	for _, arg := range options {
+		list := tcshList(cli.Name, arg)
+		if list == "" {
+			continue
+		}
+		if arg.Name != "" && arg.LongValueSeparator != "space" {
+			add(tcshRule("c", tcshLongOption(arg)+"=", list))
+		}
+		spaced, err := spacedOptionNames(arg)
+		if err != nil {
+			return err
+		}
+		for _, name := range spaced {
+			add(tcshRule("n", name, list))
+		}
+	}
+
+	// Option names, without the dashes the pattern matches.
+	long, short := []string{}, []string{}
+	for _, arg := range options {
+		if arg.Name != "" && !arg.SingleDashLong && !slices.Contains(long, arg.Name) {
+			long = append(long, arg.Name)
+		} else if arg.Name != "" && arg.SingleDashLong && !slices.Contains(short, arg.Name) {
+			short = append(short, arg.Name)
+		}
+		if arg.ShortName != "" && !slices.Contains(short, arg.ShortName) {
+			short = append(short, arg.ShortName)
+		}
+	}
+	if len(long) > 0 {
+		add(tcshRule("c", "--", "("+strings.Join(long, " ")+")"))
+	}
+	if len(short) > 0 {
+		add(tcshRule("c", "-", "("+strings.Join(short, " ")+")"))
+	}
+
+	// Subcommands, and the first positional argument of commands, after the name of the command.
+	var walk func(cmds []Command)
+	walk = func(cmds []Command) {
+		for _, cmd := range cmds {
+			list := ""
+			if len(cmd.Subcommands) > 0 {
+				list = "(" + strings.Join(commandNames(cmd.Subcommands), " ") + ")"
+			} else if positionals := collectPositionalArgs(cmd.Arguments); len(positionals) > 0 {
+				list = tcshList(cli.Name, positionals[0])
+			}
+			if list != "" {
+				for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+					add(tcshRule("n", name, list))
+				}
+			}
+			walk(cmd.Subcommands)
+		}
+	}
+	walk(cli.Commands)
+
+	// Commands, or the positional arguments of a tool without commands, by position.
+	if len(cli.Commands) > 0 {
+		add(tcshRule("p", "1", "("+strings.Join(commandNames(cli.Commands), " ")+")"))
+	} else {
+		for i, arg := range collectPositionalArgs(cli.Arguments) {
+			if list := tcshList(cli.Name, arg); list != "" {
+				add(tcshRule("p", fmt.Sprint(i+1), list))
+			}
+		}
+	}
+
+	iw.WriteLine(fmt.Sprintf("complete %s", tcshQuote(cli.Name)))
+	iw.Indent(func() error {
+		for _, rule := range rules {
+			iw.WriteLine(" \\\n")
+			iw.WriteLine(tcshQuote(rule))
+		}
+		return nil
+	})
+	iw.WriteLine("\n")
+	return nil
+}
+
+// commandNames returns the names and aliases of cmds.
+func commandNames(cmds []Command) []string {
+	names := []string{}
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name)
+		names = append(names, cmd.Aliases...)
+	}
+	return names
+}
+
+// tcshLongOption returns the long name of an option, with its dashes.
+func tcshLongOption(arg Argument) string {
+	if arg.SingleDashLong {
+		return "-" + arg.Name
+	}
+	return "--" + arg.Name
+}
+
+// tcshList returns the completion of the value of an argument as the list of a rule, or an empty
+// string if it has none.
+func tcshList(cliName string, arg Argument) string {
+	switch arg.Completion.Type {
+	case "file":
+		return "f"
+	case "folder":
+		return "d"
+	case "static":
+		return "(" + strings.Join(arg.Completion.Values, " ") + ")"
+	case "function":
+		if arg.Completion.Provider != "" {
+			return "`" + providerFunction(cliName, arg.Completion.Provider) + "`"
+		}
+		if code := tcshCommand(arg.Completion.Tcsh); code != "" {
+			return "`" + code + "`"
+		}
+	}
+	return ""
+}
+
+// tcshCommand returns code as a single line, as tcsh aliases and backquoted commands cannot span
+// several.
+func tcshCommand(code string) string {
+	lines := []string{}
+	for _, line := range strings.Split(code, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "; ")
+}
+
+// tcshRule returns a rule of complete. Its parts are separated by the first of the usual separators
+// that they do not contain.
+func tcshRule(kind, pattern, list string) string {
+	separator := "/"
+	for _, s := range []string{"/", "@", ":", ",", "%", "+", "^"} {
+		if !strings.Contains(pattern+list, s) {
+			separator = s
+			break
+		}
+	}
+	return kind + separator + pattern + separator + list + separator
+}
+
+// tcshQuote returns s single-quoted for tcsh, where history substitutions, !, are expanded even in
+// single quotes.
+func tcshQuote(s string) string {
+	return "'" + strings.NewReplacer("'", `'\''`, "!", `\!`).Replace(s) + "'"
+}
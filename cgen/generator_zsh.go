@@ -3,36 +3,57 @@ package cgen
 import (
 	"fmt"
 	"io"
-	"log"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"al.essio.dev/pkg/shellescape"
 )
 
-func GenerateZshCompletions(cli *CLI) error {
-	dir := filepath.Join("share", "zsh", "completions")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("could not create directory: %w", err)
-	}
+type zshGenerator struct{}
 
-	path := filepath.Join(dir, fmt.Sprintf("_%s", cli.Name))
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("could not create file: %w", err)
-	}
-	defer file.Close()
+func (zshGenerator) Name() string {
+	return "zsh"
+}
+
+func (zshGenerator) DefaultPath(cli *CLI) string {
+	return filepath.Join("zsh", "completions", fmt.Sprintf("_%s", cli.Name))
+}
 
-	return writeZshCompletions(cli, file)
+func (zshGenerator) Write(cli *CLI, w io.Writer) error {
+	return writeZshCompletions(cli, w)
+}
+
+func GenerateZshCompletions(cli *CLI) error {
+	return WriteFiles(zshGenerator{}, cli, "share")
 }
 
 func writeZshCompletions(cli *CLI, w io.Writer) error {
 	iw := newIndentedWriter(w, "  ")
 
 	iw.WriteLine(fmt.Sprintf("#compdef %s\n", cli.Name))
+	if err := writeHeader(w, cli, "#"); err != nil {
+		return err
+	}
 	iw.WriteLine(fmt.Sprintf("compdef _%s %s\n\n", cli.Name, cli.Name))
 
+	for _, name := range providerNames(cli) {
+		function := providerFunction(cli.Name, name)
+		iw.WriteLine(fmt.Sprintf("%s() {\n", function))
+		iw.Indent(func() error {
+			for _, line := range functionBody(cli.Providers[name].Zsh) {
+				iw.WriteLine(line + "\n")
+			}
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+		iw.WriteLine(fmt.Sprintf("%s() {\n", zshProviderCompleter(cli.Name, name)))
+		iw.Indent(func() error {
+			iw.WriteLine(fmt.Sprintf("compadd -- $(%s)\n", function))
+			return nil
+		})
+		iw.WriteLine("}\n\n")
+	}
+
 	for _, cmd := range cli.Commands {
 		if err := writeZshFunctionCompletions(iw, &cmd); err != nil {
 			return err
@@ -40,7 +61,7 @@ func writeZshCompletions(cli *CLI, w io.Writer) error {
 	}
 
 	for _, arg := range cli.Arguments {
-		if arg.Completion.Type == "function" {
+		if arg.Completion.Type == "function" && arg.Completion.Provider == "" {
 			iw.WriteLine(fmt.Sprintf("_arg_%s() {", arg.Name))
 			iw.Indent(func() error {
 				iw.WriteLine(fmt.Sprintf("compadd -- $(%s)", arg.Completion.Zsh))
@@ -50,8 +71,13 @@ func writeZshCompletions(cli *CLI, w io.Writer) error {
 		}
 	}
 
+	globals, err := formatZshNamedArguments(cli.Name, cli.Arguments)
+	if err != nil {
+		return err
+	}
+
 	iw.WriteLine(fmt.Sprintf("_%s() {\n", cli.Name))
-	iw.Indent(func() error {
+	err = iw.Indent(func() error {
 		cmds := collectCommands(cli.Commands)
 		positionals := collectPositionalArgs(cli.Arguments)
 
@@ -64,17 +90,13 @@ func writeZshCompletions(cli *CLI, w io.Writer) error {
 				count++
 			}
 			for _, arg := range positionals {
-				line := formatZshPositional(count, arg)
+				line := formatZshPositional(cli.Name, count, arg)
 				if line != "" {
 					args = append(args, line)
 					count++
 				}
 			}
-			for _, arg := range cli.Arguments {
-				if arg.Named {
-					args = append(args, generateZshArgument(arg))
-				}
-			}
+			args = append(args, globals...)
 			if len(cmds) > 0 {
 				args = append(args, "'*::args:->args'")
 			}
@@ -89,22 +111,27 @@ func writeZshCompletions(cli *CLI, w io.Writer) error {
 		})
 
 		iw.WriteLine("case $state in\n")
-		iw.Indent(func() error {
+		err := iw.Indent(func() error {
 			iw.WriteLine("args)\n")
-			iw.Indent(func() error {
+			err := iw.Indent(func() error {
 				iw.WriteLine("case ${words[1]} in\n")
 				for _, cmd := range cli.Commands {
-					writeZshCommandTree(iw, cli.Arguments, &cmd)
+					if err := writeZshCommandTree(iw, cli.Name, globals, &cmd); err != nil {
+						return err
+					}
 				}
 				iw.WriteLine("esac\n")
 				return nil
 			})
 			iw.WriteLine(";;\n")
-			return nil
+			return err
 		})
 		iw.WriteLine("esac\n")
-		return nil
+		return err
 	})
+	if err != nil {
+		return err
+	}
 	iw.WriteLine("}\n")
 	return nil
 }
@@ -119,7 +146,13 @@ func collectPositionalArgs(args []Argument) []Argument {
 	return pos
 }
 
-func formatZshPositional(count int, arg Argument) string {
+// zshProviderCompleter returns the name of the function that adds the completions printed by the
+// function of a provider.
+func zshProviderCompleter(cliName, provider string) string {
+	return strings.TrimPrefix(providerFunction(cliName, provider), "_")
+}
+
+func formatZshPositional(cliName string, count int, arg Argument) string {
 	comp := ""
 	switch arg.Completion.Type {
 	case "none":
@@ -130,13 +163,16 @@ func formatZshPositional(count int, arg Argument) string {
 		comp = fmt.Sprintf("(%s)", shellescape.QuoteCommand(arg.Completion.Values))
 	case "function":
 		comp = fmt.Sprintf("_arg_%s", arg.Name)
+		if arg.Completion.Provider != "" {
+			comp = zshProviderCompleter(cliName, arg.Completion.Provider)
+		}
 	}
 	return fmt.Sprintf(`"%d:%s:%s"`, count, arg.Name, comp)
 }
 
 func writeZshFunctionCompletions(w *indentedWriter, cmd *Command) error {
 	for _, arg := range cmd.Arguments {
-		if arg.Completion.Type == "function" {
+		if arg.Completion.Type == "function" && arg.Completion.Provider == "" {
 			w.WriteLine(fmt.Sprintf("_arg_%s() {\n", arg.Name))
 			w.Indent(func() error {
 				w.WriteLine(fmt.Sprintf("compadd -- $(%s)\n", arg.Completion.Zsh))
@@ -153,7 +189,21 @@ func writeZshFunctionCompletions(w *indentedWriter, cmd *Command) error {
 	return nil
 }
 
-func generateZshArgument(arg Argument) string {
+func formatZshNamedArguments(cliName string, args []Argument) ([]string, error) {
+	out := []string{}
+	for _, arg := range args {
+		if arg.Named {
+			line, err := generateZshArgument(cliName, arg)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, line)
+		}
+	}
+	return out, nil
+}
+
+func generateZshArgument(cliName string, arg Argument) (string, error) {
 	comp := ""
 	switch arg.Completion.Type {
 	case "file", "folder":
@@ -162,6 +212,9 @@ func generateZshArgument(arg Argument) string {
 		comp = fmt.Sprintf("(%s)", shellescape.QuoteCommand(arg.Completion.Values))
 	case "function":
 		comp = fmt.Sprintf("_arg_%s", arg.Name)
+		if arg.Completion.Provider != "" {
+			comp = zshProviderCompleter(cliName, arg.Completion.Provider)
+		}
 	}
 	dash := "-"
 	if !arg.SingleDashLong {
@@ -176,8 +229,7 @@ func generateZshArgument(arg Argument) string {
 	case "both":
 		longSeparatorSuffix = "="
 	default:
-		log.Fatalf("The option %s is not valid for long-value-separator. Accepted values are space, equal and both", arg.LongValueSeparator)
-		os.Exit(1)
+		return "", errInvalidValue(arg, "long-value-separator", arg.LongValueSeparator, longValueSeparators)
 	}
 	name := arg.Name + longSeparatorSuffix
 	shortSeparatorSuffix := ""
@@ -188,8 +240,7 @@ func generateZshArgument(arg Argument) string {
 	case "both":
 		shortSeparatorSuffix = "+"
 	default:
-		log.Fatalf("The option %s is not valid for short-value-separator. Accepted values are space, equal and both", arg.ShortValueSeparator)
-		os.Exit(1)
+		return "", errInvalidValue(arg, "short-value-separator", arg.ShortValueSeparator, shortValueSeparators)
 	}
 	shortName := arg.ShortName + shortSeparatorSuffix
 	argName := arg.Name
@@ -200,19 +251,24 @@ func generateZshArgument(arg Argument) string {
 		comp = fmt.Sprintf(":%s:%s", argName, comp)
 	}
 	if arg.Name != "" && arg.ShortName != "" {
-		return fmt.Sprintf("'(-%s %s%s)'{-%s,%s%s}'[%s]%s'", shortName, dash, name, arg.ShortName, dash, name, desc, comp)
+		return fmt.Sprintf("'(-%s %s%s)'{-%s,%s%s}'[%s]%s'", shortName, dash, name, arg.ShortName, dash, name, desc, comp), nil
 	} else if arg.Name != "" {
-		return fmt.Sprintf("'%s%s[%s]%s'", dash, name, desc, comp)
+		return fmt.Sprintf("'%s%s[%s]%s'", dash, name, desc, comp), nil
 	} else {
-		return fmt.Sprintf("'-%s[%s]%s'", shortName, desc, comp)
+		return fmt.Sprintf("'-%s[%s]%s'", shortName, desc, comp), nil
 	}
 }
 
-func writeZshCommandTree(w *indentedWriter, global_arguments []Argument, cmd *Command) error {
+func writeZshCommandTree(w *indentedWriter, cliName string, global_arguments []string, cmd *Command) error {
 	if len(cmd.Arguments) == 0 && len(cmd.Subcommands) == 0 {
 		return nil
 	}
 
+	named, err := formatZshNamedArguments(cliName, cmd.Arguments)
+	if err != nil {
+		return err
+	}
+
 	names := append([]string{cmd.Name}, cmd.Aliases...)
 	for _, name := range names {
 		w.WriteLine(fmt.Sprintf("%s)\n", name))
@@ -226,21 +282,13 @@ func writeZshCommandTree(w *indentedWriter, global_arguments []Argument, cmd *Co
 				count := 1
 				for _, arg := range cmd.Arguments {
 					if !arg.Named {
-						args = append(args, formatZshPositional(count, arg))
+						args = append(args, formatZshPositional(cliName, count, arg))
 						count++
 					}
 				}
 			}
-			for _, arg := range cmd.Arguments {
-				if arg.Named {
-					args = append(args, generateZshArgument(arg))
-				}
-			}
-			for _, arg := range global_arguments {
-				if arg.Named {
-					args = append(args, generateZshArgument(arg))
-				}
-			}
+			args = append(args, named...)
+			args = append(args, global_arguments...)
 			if len(cmd.Subcommands) > 0 {
 				args = append(args, fmt.Sprintf("'*::args:->args_%s'", cmd.Name))
 			}
@@ -259,23 +307,26 @@ func writeZshCommandTree(w *indentedWriter, global_arguments []Argument, cmd *Co
 			}
 			if len(cmd.Subcommands) > 0 {
 				w.WriteLine("case $state in\n")
-				w.Indent(func() error {
+				err := w.Indent(func() error {
 					w.WriteLine(fmt.Sprintf("args_%s)\n", cmd.Name))
-					w.Indent(func() error {
+					err := w.Indent(func() error {
 						w.WriteLine("case ${words[1]} in\n")
-						w.Indent(func() error {
+						err := w.Indent(func() error {
 							for _, sub := range cmd.Subcommands {
-								writeZshCommandTree(w, global_arguments, &sub)
+								if err := writeZshCommandTree(w, cliName, global_arguments, &sub); err != nil {
+									return err
+								}
 							}
 							return nil
 						})
 						w.WriteLine("esac\n")
-						return nil
+						return err
 					})
 					w.WriteLine(";;\n")
-					return nil
+					return err
 				})
 				w.WriteLine("esac\n")
+				return err
 			}
 			return nil
 		})
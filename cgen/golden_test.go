@@ -0,0 +1,70 @@
+package cgen
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the generators")
+
+// goldenTargets are the generators whose output is compared with the golden files of the test cases,
+// as test/run.fish only runs the completions of bash, fish and zsh.
+var goldenTargets = []string{"powershell", "nushell", "elvish", "tcsh", "emacs"}
+
+// versionLine matches the line of the header with the version of cgen, which golden files leave out
+// so that releases do not change them.
+var versionLine = regexp.MustCompile(`(?m)^\S+ Generated by cgen v.*\n`)
+
+func TestGoldenOutputs(t *testing.T) {
+	specs, err := filepath.Glob(filepath.Join("..", "test", "*", "cli.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range specs {
+		dir := filepath.Dir(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spec, err := ParseSpec(path, data)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if ds := append(spec.Interpolate(false), spec.Validate()...); len(ds) > 0 {
+			t.Fatalf("%s: %v", path, ds)
+		}
+
+		for _, target := range goldenTargets {
+			t.Run(filepath.Base(dir)+"/"+target, func(t *testing.T) {
+				g, ok := LookupGenerator(target)
+				if !ok {
+					t.Fatalf("unknown target %q", target)
+				}
+				var b bytes.Buffer
+				if err := g.Write(spec.CLI, &b); err != nil {
+					t.Fatal(err)
+				}
+				got := versionLine.ReplaceAllString(b.String(), "")
+
+				golden := filepath.Join(dir, target+".golden")
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v, run go test ./cgen -update to create it", err)
+				}
+				if got != string(want) {
+					t.Errorf("the output differs from %s, run go test ./cgen -update to accept it:\n%s", golden, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package cgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+)
+
+// includeKeys are the keys of the lists whose items can be read from other files, with an item like
+// `include: commands/*.yml`.
+var includeKeys = []string{"commands", "arguments"}
+
+// expandIncludes replaces, in the lists of commands and arguments of node and of its commands, the
+// include items by the items of the files they name. dir is the directory that relative paths are
+// resolved against and stack the absolute paths of the files being included, to detect cycles.
+func (s *Spec) expandIncludes(node ast.Node, dir string, stack []string, ds *[]Diagnostic) {
+	entries, _ := mappingEntries(node)
+	for _, entry := range entries {
+		if !slices.Contains(includeKeys, entry.Key.GetToken().Value) {
+			continue
+		}
+		seq, ok := unwrapNode(entry.Value).(*ast.SequenceNode)
+		if !ok {
+			continue
+		}
+		seq.Values = s.expandItems(seq.Values, dir, stack, ds)
+		seq.ValueHeadComments = nil
+	}
+}
+
+// expandItems returns the items of a list of commands or arguments with its include items replaced.
+func (s *Spec) expandItems(values []ast.Node, dir string, stack []string, ds *[]Diagnostic) []ast.Node {
+	items := []ast.Node{}
+	for _, value := range values {
+		entries, _ := mappingEntries(value)
+		if len(entries) != 1 || entries[0].Key.GetToken().Value != "include" {
+			s.expandIncludes(value, dir, stack, ds)
+			items = append(items, value)
+			continue
+		}
+		items = append(items, s.include(entries[0].Value, dir, stack, ds)...)
+	}
+	return items
+}
+
+// include returns the items of the files matched by the path or glob pattern in node.
+func (s *Spec) include(node ast.Node, dir string, stack []string, ds *[]Diagnostic) []ast.Node {
+	fail := func(rule, message string) []ast.Node {
+		d := Diagnostic{Severity: SeverityError, Rule: rule, Message: message}
+		s.locate(&d, node.GetToken())
+		*ds = append(*ds, d)
+		return nil
+	}
+
+	value, ok := unwrapNode(node).(*ast.StringNode)
+	if !ok || value.Value == "" {
+		return fail("decode-error", "include must be the path of a file")
+	}
+	pattern := value.Value
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return fail("include-not-found", fmt.Sprintf("invalid pattern %q: %s", value.Value, err))
+	}
+	if len(paths) == 0 {
+		return fail("include-not-found", fmt.Sprintf("no file matches %q", value.Value))
+	}
+
+	items := []ast.Node{}
+	for _, path := range paths {
+		abs, _ := filepath.Abs(path)
+		if i := slices.Index(stack, abs); i >= 0 {
+			cycle := append(slices.Clone(stack[i:]), abs)
+			for j := range cycle {
+				cycle[j] = filepath.Base(cycle[j])
+			}
+			fail("include-cycle", fmt.Sprintf("%s includes itself: %s", path, strings.Join(cycle, " -> ")))
+			continue
+		}
+
+		root, err := s.parseIncluded(path)
+		if err != nil {
+			if d, ok := err.(*DiagnosticError); ok {
+				*ds = append(*ds, d.Diagnostics...)
+			} else {
+				fail("include-not-found", err.Error())
+			}
+			continue
+		}
+
+		switch n := unwrapNode(root).(type) {
+		case nil, *ast.NullNode:
+		case *ast.SequenceNode:
+			items = append(items, s.expandItems(n.Values, filepath.Dir(path), append(stack, abs), ds)...)
+		case *ast.MappingNode, *ast.MappingValueNode:
+			items = append(items, s.expandItems([]ast.Node{root}, filepath.Dir(path), append(stack, abs), ds)...)
+		default:
+			d := Diagnostic{Severity: SeverityError, Rule: "decode-error", Message: "an included file must hold a list of items or a single one"}
+			s.locate(&d, root.GetToken())
+			*ds = append(*ds, d)
+		}
+	}
+	return items
+}
+
+// parseIncluded parses an included file, in the format given by its extension, and records the file
+// of its tokens so that diagnostics point into it.
+func (s *Spec) parseIncluded(path string) (ast.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(s.Files, path) {
+		s.Files = append(s.Files, path)
+	}
+
+	included := &Spec{File: path, Format: SpecFormat(path), lines: strings.Split(string(data), "\n")}
+	if err := included.parse(data); err != nil {
+		return nil, err
+	}
+
+	if included.root == nil {
+		return nil, nil
+	}
+	if s.sources == nil {
+		s.sources = map[*token.Token]*specSource{}
+	}
+	src := &specSource{path, included.Format, included.lines}
+	ast.Walk(visitorFunc(func(node ast.Node) {
+		if tk := node.GetToken(); tk != nil {
+			s.sources[tk] = src
+		}
+	}), included.root)
+	return included.root, nil
+}
@@ -0,0 +1,138 @@
+package cgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		commands []string
+
+		// The diagnostic expected instead of commands, at file:line:column.
+		rule     string
+		position string
+		message  string
+	}{
+		{
+			name:     "single item",
+			files:    map[string]string{"commands/add.yml": "name: add\n"},
+			commands: []string{"add"},
+		},
+		{
+			name:     "list of items",
+			files:    map[string]string{"commands/add.yml": "- name: add\n- name: remove\n"},
+			commands: []string{"add", "remove"},
+		},
+		{
+			name:     "glob in alphabetical order",
+			files:    map[string]string{"commands/b.yml": "name: b\n", "commands/a.yml": "name: a\n"},
+			commands: []string{"a", "b"},
+		},
+		{
+			name: "nested relative to the included file",
+			files: map[string]string{
+				"commands/remote.yml":     "name: remote\ncommands:\n  - include: remote/*.yml\n",
+				"commands/remote/add.yml": "name: add\n",
+			},
+			commands: []string{"remote", "remote add"},
+		},
+		{
+			name: "other formats",
+			files: map[string]string{
+				"commands/remote.yml":  "name: remote\ncommands:\n  - include: remote.json\n",
+				"commands/remote.json": `[{"name": "add"}, {"name": "remove"}]`,
+			},
+			commands: []string{"remote", "remote add", "remote remove"},
+		},
+		{
+			name:     "no matching file",
+			files:    map[string]string{"other/add.yml": "name: add\n"},
+			rule:     "include-not-found",
+			position: "cli.yml:3:14",
+			message:  `no file matches "commands/*.yml"`,
+		},
+		{
+			name:     "includes itself",
+			files:    map[string]string{"commands/a.yml": "name: a\ncommands:\n  - include: a.yml\n"},
+			rule:     "include-cycle",
+			position: "a.yml:3:14",
+			message:  "a.yml -> a.yml",
+		},
+		{
+			name: "indirect cycle",
+			files: map[string]string{
+				"commands/a.yml": "name: a\ncommands:\n  - include: ../b.yml\n",
+				"b.yml":          "name: b\ncommands:\n  - include: commands/a.yml\n",
+			},
+			rule:     "include-cycle",
+			position: "b.yml:3:14",
+			message:  "a.yml -> b.yml -> a.yml",
+		},
+		{
+			name:     "error in an included file",
+			files:    map[string]string{"commands/add.yml": "name: add\naliass: [a]\n"},
+			rule:     "unknown-field",
+			position: "add.yml:2:1",
+		},
+		{
+			name:     "scalar file",
+			files:    map[string]string{"commands/add.yml": "add\n"},
+			rule:     "decode-error",
+			position: "add.yml:1:1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["cli.yml"] = "name: cli\ncommands:\n  - include: commands/*.yml\n"
+			dir := writeFiles(t, tt.files)
+			path := filepath.Join(dir, "cli.yml")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec, err := ParseSpecFormat(path, data, "yaml")
+			ds := specDiagnostics(t, err)
+
+			if tt.rule == "" {
+				if len(ds) > 0 {
+					t.Fatalf("unexpected diagnostics: %v", ds)
+				}
+				if names := commandPaths(spec.CLI.Commands, ""); !slices.Equal(names, tt.commands) {
+					t.Errorf("commands = %v, want %v", names, tt.commands)
+				}
+				return
+			}
+			if len(ds) != 1 || ds[0].Rule != tt.rule {
+				t.Fatalf("diagnostics = %v, want one %s", ds, tt.rule)
+			}
+			p := ds[0].Position
+			if p == nil {
+				t.Fatal("the diagnostic has no position")
+			}
+			if got := fmt.Sprintf("%s:%d:%d", filepath.Base(p.File), p.Line, p.Column); got != tt.position {
+				t.Errorf("position = %s, want %s", got, tt.position)
+			}
+			if !strings.Contains(ds[0].Message, tt.message) {
+				t.Errorf("message = %q, want it to contain %q", ds[0].Message, tt.message)
+			}
+		})
+	}
+}
+
+// commandPaths returns the names of the commands, and of their subcommands after them, prefixed by the
+// names of their parents.
+func commandPaths(commands []Command, prefix string) []string {
+	paths := []string{}
+	for _, c := range commands {
+		paths = append(paths, prefix+c.Name)
+		paths = append(paths, commandPaths(c.Subcommands, prefix+c.Name+" ")...)
+	}
+	return paths
+}
@@ -0,0 +1,150 @@
+package cgen
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// variablePattern matches a reference to a variable, ${variable}, or an escaped one, $${variable}.
+var variablePattern = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// builtinVariables are the variables every description has.
+var builtinVariables = []string{"name", "version", "command.path"}
+
+// Interpolate replaces the references to variables in the descriptions, usages, examples and
+// deprecation notes of the tool, of its commands and of their arguments. A reference is written
+// ${variable}, and $${ is written as ${. The variables are the name and version of the tool,
+// command.path, which is the tool and the commands leading to the text, and the ones in Vars, which
+// can reference others. If env is true, env.NAME is the environment variable NAME.
+//
+// It returns the references to undefined variables, which are left as written.
+func Interpolate(cli *CLI, env bool) []Diagnostic {
+	in := interpolator{cli: cli, env: env, seen: map[string]bool{}}
+	for _, name := range slices.Sorted(maps.Keys(cli.Vars)) {
+		if slices.Contains(builtinVariables, name) || strings.HasPrefix(name, "env.") {
+			in.report("invalid-value", "vars."+name, "", "%q is a built-in variable and cannot be redefined", name)
+		}
+		in.expand(cli.Vars[name], "vars."+name, nil, []string{name})
+	}
+
+	path := []string{cli.Name}
+	in.text(&cli.ShortDescription, "short-description", path)
+	in.text(&cli.LongDescription, "long-description", path)
+	in.arguments(cli.Arguments, "", path)
+	in.commands(cli.Commands, "", path)
+	return in.diagnostics
+}
+
+type interpolator struct {
+	cli *CLI
+	env bool
+
+	diagnostics []Diagnostic
+
+	// Diagnostics already reported, by path and message, as a variable is expanded at every use.
+	seen map[string]bool
+}
+
+func (in *interpolator) report(rule, path, suggestion, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if in.seen[path+"\n"+message] {
+		return
+	}
+	in.seen[path+"\n"+message] = true
+	in.diagnostics = append(in.diagnostics, Diagnostic{
+		Severity:   SeverityError,
+		Rule:       rule,
+		Path:       path,
+		Message:    message,
+		Suggestion: suggestion,
+	})
+}
+
+func (in *interpolator) commands(cmds []Command, path string, command []string) {
+	for i := range cmds {
+		cmd := &cmds[i]
+		cmdPath := fmt.Sprintf("%scommands[%d].", path, i)
+		names := slices.Concat(command, []string{cmd.Name})
+		in.text(&cmd.ShortDescription, cmdPath+"short-description", names)
+		in.text(&cmd.LongDescription, cmdPath+"long-description", names)
+		in.text(&cmd.Usage, cmdPath+"usage", names)
+		in.text(&cmd.Example, cmdPath+"example", names)
+		in.text(&cmd.Deprecated, cmdPath+"deprecated", names)
+		in.arguments(cmd.Arguments, cmdPath, names)
+		in.commands(cmd.Subcommands, cmdPath, names)
+	}
+}
+
+func (in *interpolator) arguments(args []Argument, path string, command []string) {
+	for i := range args {
+		arg := &args[i]
+		argPath := fmt.Sprintf("%sarguments[%d].", path, i)
+		in.text(&arg.ShortDescription, argPath+"short-description", command)
+		in.text(&arg.LongDescription, argPath+"long-description", command)
+		in.text(&arg.Example, argPath+"example", command)
+		in.text(&arg.Deprecated, argPath+"deprecated", command)
+	}
+}
+
+// text interpolates the text at path, which belongs to command.
+func (in *interpolator) text(text *string, path string, command []string) {
+	*text = in.expand(*text, path, command, nil)
+}
+
+// expand returns text with its references replaced. stack holds the user variables being expanded,
+// to detect cycles.
+func (in *interpolator) expand(text, path string, command []string, stack []string) string {
+	return variablePattern.ReplaceAllStringFunc(text, func(reference string) string {
+		if strings.HasPrefix(reference, "$$") {
+			return reference[1:]
+		}
+		name := variablePattern.FindStringSubmatch(reference)[1]
+		if value, ok := in.value(name, path, command, stack); ok {
+			return value
+		}
+		return reference
+	})
+}
+
+// value returns the value of the variable name, reporting it at path if it is undefined.
+func (in *interpolator) value(name, path string, command []string, stack []string) (string, bool) {
+	switch {
+	case name == "name":
+		return in.cli.Name, true
+	case name == "version":
+		return in.cli.Version, true
+	case name == "command.path":
+		if command == nil {
+			// Variables are expanded again in every text that uses them, with its command.
+			return "", false
+		}
+		return strings.Join(command, " "), true
+	case strings.HasPrefix(name, "env."):
+		if !in.env {
+			in.report("undefined-variable", path, "", "variable %q is undefined, as environment variables are not enabled", name)
+			return "", false
+		}
+		value, ok := os.LookupEnv(strings.TrimPrefix(name, "env."))
+		if !ok {
+			in.report("undefined-variable", path, "", "environment variable %q is not set", strings.TrimPrefix(name, "env."))
+		}
+		return value, ok
+	}
+
+	value, ok := in.cli.Vars[name]
+	if !ok {
+		names := slices.Concat(builtinVariables, slices.Sorted(maps.Keys(in.cli.Vars)))
+		in.report("undefined-variable", path, suggest(name, names), "variable %q is undefined", name)
+		return "", false
+	}
+	if i := slices.Index(stack, name); i >= 0 {
+		cycle := append(slices.Clone(stack[i:]), name)
+		in.report("undefined-variable", "vars."+stack[len(stack)-1], "", "variable %q references itself: %s", name, strings.Join(cycle, " -> "))
+		return "", false
+	}
+	return in.expand(value, "vars."+name, command, append(stack, name)), true
+}
@@ -0,0 +1,111 @@
+package cgen
+
+import (
+	"fmt"
+	"testing"
+)
+
+// interpolatedSpec is a description whose subcommand has the short description given to it.
+const interpolatedSpec = `spec-version: 2
+name: tool
+version: 2.1.0
+vars:
+  docs: https://example.com/${name}
+  site: ${docs}/site
+commands:
+  - name: remote
+    commands:
+      - name: add
+        short-description: '%s'
+`
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("CGEN_TEST", "value")
+	tests := []struct {
+		name string
+		text string
+		env  bool
+		want string
+	}{
+		{"plain text", "Adds a remote", false, "Adds a remote"},
+		{"built-in variables", "${name} ${version}", false, "tool 2.1.0"},
+		{"command path", "Run ${command.path} --help", false, "Run tool remote add --help"},
+		{"variables referencing others", "See ${site}", false, "See https://example.com/tool/site"},
+		{"escaped reference", "$${name} is ${name}", false, "${name} is tool"},
+		{"environment variable", "${env.CGEN_TEST}", true, "value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec("cli.yml", fmt.Appendf(nil, interpolatedSpec, tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ds := spec.Interpolate(tt.env); len(ds) > 0 {
+				t.Fatalf("unexpected diagnostics: %v", ds)
+			}
+			if got := spec.CLI.Commands[0].Subcommands[0].ShortDescription; got != tt.want {
+				t.Errorf("short description = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		env        bool
+		path       string
+		suggestion string
+	}{
+		{"undefined variable", "${nme}", false, "commands[0].commands[0].short-description", "name"},
+		{"disabled environment", "${env.CGEN_TEST}", false, "commands[0].commands[0].short-description", ""},
+		{"unset environment variable", "${env.CGEN_UNSET}", true, "commands[0].commands[0].short-description", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec("cli.yml", fmt.Appendf(nil, interpolatedSpec, tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ds := spec.Interpolate(tt.env)
+			if len(ds) != 1 || ds[0].Rule != "undefined-variable" || ds[0].Path != tt.path {
+				t.Fatalf("diagnostics = %v, want one undefined-variable at %s", ds, tt.path)
+			}
+			if p := ds[0].Position; p == nil || p.Line != 11 || p.Column != 28 {
+				t.Errorf("position = %v, want 11:28", p)
+			}
+			if ds[0].Suggestion != tt.suggestion {
+				t.Errorf("suggestion = %q, want %q", ds[0].Suggestion, tt.suggestion)
+			}
+			if got := spec.CLI.Commands[0].Subcommands[0].ShortDescription; got != tt.text {
+				t.Errorf("short description = %q, want it left as %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestInterpolateVariables(t *testing.T) {
+	tests := []struct {
+		name string
+		vars string
+		rule string
+		path string
+	}{
+		{"cycle", "  docs: See ${docs}\n", "undefined-variable", "vars.docs"},
+		{"redefined built-in", "  version: 1.0\n", "invalid-value", "vars.version"},
+		{"redefined environment variable", "  env.HOME: /root\n", "invalid-value", "vars.env.HOME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec("cli.yml", []byte("spec-version: 2\nname: tool\nvars:\n"+tt.vars))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ds := spec.Interpolate(false)
+			if len(ds) != 1 || ds[0].Rule != tt.rule || ds[0].Path != tt.path {
+				t.Errorf("diagnostics = %v, want one %s at %s", ds, tt.rule, tt.path)
+			}
+		})
+	}
+}
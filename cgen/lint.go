@@ -0,0 +1,144 @@
+package cgen
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Lint checks a CLI description for mistakes that decoding accepts, like duplicated names or
+// function completions missing the code for a shell. The errors of Validate are reported too.
+func Lint(cli *CLI) []Diagnostic {
+	l := linter{globals: cli.Arguments}
+	if err, ok := Validate(cli).(*ValidationError); ok {
+		l.diagnostics = err.Diagnostics()
+	}
+	for _, name := range providerNames(cli) {
+		provider := cli.Providers[name]
+		if missing := missingSnippets(providerSnippets(&provider)); len(missing) > 0 {
+			l.report(SeverityWarning, "missing-function-snippet", "providers."+name, "provider has no code for %s", strings.Join(missing, ", "))
+		}
+	}
+	l.arguments("", cli.Arguments, true)
+	l.commands("", cli.Commands)
+	return l.diagnostics
+}
+
+type linter struct {
+	globals     []Argument
+	diagnostics []Diagnostic
+}
+
+func (l *linter) report(severity Severity, rule, path, format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Severity: severity,
+		Rule:     rule,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) commands(path string, cmds []Command) {
+	seen := map[string]bool{}
+	for i, cmd := range cmds {
+		cmdPath := fmt.Sprintf("%scommands[%d]", path, i)
+		for j, name := range slices.Concat([]string{cmd.Name}, cmd.Aliases) {
+			if seen[name] && j == 0 {
+				l.report(SeverityError, "duplicate-command-name", cmdPath+".name", "%q is already the name or alias of a sibling command", name)
+			} else if seen[name] {
+				l.report(SeverityError, "alias-collision", fmt.Sprintf("%s.aliases[%d]", cmdPath, j-1), "the alias %q is already the name or alias of a sibling command", name)
+			}
+			seen[name] = true
+		}
+		l.arguments(cmdPath+".", cmd.Arguments, false)
+		l.commands(cmdPath+".", cmd.Subcommands)
+	}
+}
+
+func (l *linter) arguments(path string, args []Argument, global bool) {
+	names := map[string]bool{}
+	shortNames := map[string]bool{}
+	for i, arg := range args {
+		argPath := fmt.Sprintf("%sarguments[%d]", path, i)
+
+		if arg.Named {
+			if arg.Name != "" {
+				if names[arg.Name] {
+					l.report(SeverityError, "duplicate-name", argPath+".name", "the option %q is defined more than once", arg.Name)
+				} else if !global && slices.ContainsFunc(l.globals, func(g Argument) bool { return g.Named && g.Name == arg.Name }) {
+					l.report(SeverityWarning, "shadowed-global-option", argPath+".name", "the option %q shadows a global option", arg.Name)
+				}
+				names[arg.Name] = true
+			}
+			if arg.ShortName != "" {
+				if shortNames[arg.ShortName] {
+					l.report(SeverityError, "duplicate-short-name", argPath+".short-name", "the short option %q is defined more than once", arg.ShortName)
+				} else if !global && slices.ContainsFunc(l.globals, func(g Argument) bool { return g.Named && g.ShortName == arg.ShortName }) {
+					l.report(SeverityWarning, "shadowed-global-option", argPath+".short-name", "the short option %q shadows a global option", arg.ShortName)
+				}
+				shortNames[arg.ShortName] = true
+			}
+			if arg.ValueLabel != "" && arg.Completion.Type == "none" {
+				l.report(SeverityWarning, "value-label-without-value", argPath+".value-label", "value-label has no effect on an option that takes no value")
+			}
+		}
+
+		switch arg.Completion.Type {
+		case "static":
+			if len(arg.Completion.Values) == 0 {
+				l.report(SeverityError, "empty-static-completion", argPath+".completion", "static completion has no values")
+			}
+		case "function":
+			if arg.Completion.Provider != "" {
+				break
+			}
+			if missing := missingSnippets(completionSnippets(&arg.Completion)); len(missing) > 0 {
+				l.report(SeverityWarning, "missing-function-snippet", argPath+".completion", "function completion has no code for %s", strings.Join(missing, ", "))
+			}
+		}
+	}
+}
+
+type completionSnippet struct {
+	shell string
+	code  string
+}
+
+// Per-shell code of a function completion.
+func completionSnippets(c *Completion) []completionSnippet {
+	return []completionSnippet{
+		{"bash", c.Bash},
+		{"fish", c.Fish},
+		{"zsh", c.Zsh},
+		{"powershell", c.PowerShell},
+		{"nushell", c.Nushell},
+		{"elvish", c.Elvish},
+		{"tcsh", c.Tcsh},
+		{"elisp", c.Elisp},
+	}
+}
+
+// Per-shell code of a provider.
+func providerSnippets(p *Provider) []completionSnippet {
+	return []completionSnippet{
+		{"bash", p.Bash},
+		{"fish", p.Fish},
+		{"zsh", p.Zsh},
+		{"powershell", p.PowerShell},
+		{"nushell", p.Nushell},
+		{"elvish", p.Elvish},
+		{"tcsh", p.Tcsh},
+		{"elisp", p.Elisp},
+	}
+}
+
+// missingSnippets returns the shells that have no code.
+func missingSnippets(snippets []completionSnippet) []string {
+	missing := []string{}
+	for _, s := range snippets {
+		if strings.TrimSpace(s.code) == "" {
+			missing = append(missing, s.shell)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,263 @@
+package cgen
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+)
+
+// SpecVersion is the version of the description format that this version of cgen reads and writes.
+// Descriptions without a spec-version are read as version 1, the format before versions.
+const SpecVersion = 2
+
+// Change is a transformation made to a description to upgrade it to a newer version of the format.
+type Change struct {
+	// Version of the format the change upgrades to.
+	Version int
+
+	// Location of the changed value in the description file, e.g. commands[0].short-description.
+	Path string
+
+	Message string
+
+	// Where the changed value is, if known.
+	Position *Position
+
+	// Whether the change must be made by hand, as the value is in an included file or an overlay,
+	// which are not rewritten.
+	Manual bool
+
+	// Token of the changed value.
+	token *token.Token
+}
+
+func (c Change) String() string {
+	var b strings.Builder
+	if c.Position != nil {
+		b.WriteString(c.Position.String() + ": ")
+	}
+	if c.Path != "" {
+		b.WriteString(c.Path + ": ")
+	}
+	b.WriteString(c.Message)
+	if c.Manual {
+		b.WriteString(" (to do by hand)")
+	}
+	return b.String()
+}
+
+// migration upgrades the tree of a description from the previous version of the format.
+type migration struct {
+	version int
+
+	// Top-level keys introduced by the version, which older descriptions cannot use.
+	keys []string
+
+	// apply changes the tree under root, reporting each change.
+	apply func(root ast.Node, change func(path string, tk *token.Token, message string))
+
+	// Lint warning about the values it changes in descriptions without a spec-version.
+	warning string
+}
+
+// migrations are the upgrades from each version of the format to the next, in order.
+var migrations = []migration{
+	{
+		version: 2,
+		keys:    []string{"vars"},
+		apply:   escapeVariables,
+		warning: "${ is kept as written, as the description has no spec-version and is read as version 1: set spec-version to 2 to reference a variable, or run cgen migrate to keep it",
+	},
+}
+
+// migrationWarning returns the lint warning of the migration to version.
+func migrationWarning(version int) string {
+	for _, m := range migrations {
+		if m.version == version {
+			return m.warning
+		}
+	}
+	return ""
+}
+
+// textFields are the keys of the texts of the tool, commands and arguments, which reference variables
+// since version 2.
+var textFields = []string{"short-description", "long-description", "usage", "example", "deprecated"}
+
+// specVersion returns the version of the format of the description under root, or 0 if its
+// spec-version is invalid.
+func (s *Spec) specVersion(root ast.Node, ds *[]Diagnostic) int {
+	entry := mappingEntry(root, "spec-version")
+	if entry == nil {
+		return 1
+	}
+
+	value := unwrapNode(entry.Value)
+	version, err := strconv.Atoi(value.GetToken().Value)
+	if err == nil && version >= 1 && version <= SpecVersion {
+		return version
+	}
+	message := fmt.Sprintf("spec-version must be a version from 1 to %d", SpecVersion)
+	if err == nil && version > SpecVersion {
+		message = fmt.Sprintf("spec-version %d is newer than the versions this cgen can read, up to %d", version, SpecVersion)
+	}
+	d := Diagnostic{Severity: SeverityError, Rule: "unsupported-spec-version", Path: "spec-version", Message: message}
+	s.locate(&d, value.GetToken())
+	*ds = append(*ds, d)
+	return 0
+}
+
+// checkVersionKeys reports the top-level keys of root that need a newer version of the format.
+func (s *Spec) checkVersionKeys(root ast.Node, version int, ds *[]Diagnostic) {
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		for _, key := range m.keys {
+			if entry := mappingEntry(root, key); entry != nil {
+				d := Diagnostic{
+					Severity: SeverityError,
+					Rule:     "unsupported-spec-version",
+					Path:     key,
+					Message:  fmt.Sprintf("%s needs spec-version %d or later, run cgen migrate to upgrade the description", key, m.version),
+				}
+				s.locate(&d, entry.Key.GetToken())
+				*ds = append(*ds, d)
+			}
+		}
+	}
+}
+
+// upgrade applies to the tree under root the migrations from version to SpecVersion, returning the
+// changes made.
+func (s *Spec) upgrade(root ast.Node, version int) []Change {
+	changes := []Change{}
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		m.apply(root, func(path string, tk *token.Token, message string) {
+			var d Diagnostic
+			s.locate(&d, tk)
+			changes = append(changes, Change{
+				Version:  m.version,
+				Path:     path,
+				Message:  message,
+				Position: d.Position,
+				Manual:   s.sources[tk] != nil,
+				token:    tk,
+			})
+		})
+	}
+	return changes
+}
+
+// Migrate returns the description upgraded to SpecVersion, in its canonical form, and the changes
+// made. Comments are kept. The changes that included files and overlays need are returned too, but
+// they are not made. Descriptions already at SpecVersion are returned as they are, with no changes.
+// Only YAML descriptions can be migrated.
+func (s *Spec) Migrate() ([]byte, []Change, error) {
+	if s.Format != "yaml" {
+		return nil, nil, fmt.Errorf("only YAML descriptions can be migrated, %s is %s", s.File, strings.ToUpper(s.Format))
+	}
+	version := max(s.CLI.SpecVersion, 1)
+	if version == SpecVersion {
+		return s.Bytes(), nil, nil
+	}
+
+	changes := s.upgrade(s.original, version)
+	for _, c := range s.changes {
+		if c.Manual {
+			changes = append(changes, c)
+		}
+	}
+
+	tk := token.New(strconv.Itoa(SpecVersion), strconv.Itoa(SpecVersion), &token.Position{Line: 1, Column: 1})
+	if entry := mappingEntry(s.original, "spec-version"); entry != nil {
+		entry.Value = ast.Integer(tk)
+	} else {
+		key := ast.String(token.New("spec-version", "spec-version", &token.Position{Line: 1, Column: 1}))
+		entry := ast.MappingValue(token.MappingValue(&token.Position{Line: 1, Column: 1}), key, ast.Integer(tk))
+		switch n := unwrapNode(s.original).(type) {
+		case *ast.MappingNode:
+			// The comment above the first key is usually about the file, so it stays at the top.
+			if len(n.Values) > 0 && n.Values[0].GetComment() != nil {
+				entry.SetComment(n.Values[0].GetComment())
+				n.Values[0].SetComment(nil)
+			}
+			n.Values = slices.Insert(n.Values, 0, entry)
+		case *ast.MappingValueNode:
+			s.original = ast.Mapping(n.GetToken(), false, entry, n)
+		default:
+			s.original = entry
+		}
+	}
+	changes = append(changes, Change{Version: SpecVersion, Path: "spec-version", Message: fmt.Sprintf("set spec-version to %d", SpecVersion)})
+
+	formatted, err := s.Canonical()
+	return formatted, changes, err
+}
+
+// escapeVariables writes the ${ of texts as $${, as ${ references a variable since version 2.
+func escapeVariables(root ast.Node, change func(path string, tk *token.Token, message string)) {
+	done := map[ast.Node]bool{}
+	var walk func(node ast.Node, t reflect.Type, path string)
+	walk = func(node ast.Node, t reflect.Type, path string) {
+		node = unwrapNode(node)
+		if node == nil || done[node] {
+			return
+		}
+		done[node] = true
+
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := specFields(t)
+			entries, _ := mappingEntries(node)
+			for _, e := range entries {
+				i := slices.IndexFunc(fields, func(f specField) bool { return f.Name == e.Key.GetToken().Value })
+				if i < 0 {
+					continue
+				}
+				fieldPath := joinPath(path, fields[i].Name)
+				if fields[i].Type.Kind() != reflect.String || !slices.Contains(textFields, fields[i].Name) {
+					walk(e.Value, fields[i].Type, fieldPath)
+				} else if value := unwrapNode(e.Value); !done[value] {
+					done[value] = true
+					if text := textNode(value); text != nil && strings.Contains(text.Value, "${") {
+						change(fieldPath, value.GetToken(), "wrote ${ as $${, as it now references a variable")
+						text.Value = strings.ReplaceAll(text.Value, "${", "$${")
+						text.Token.Value = text.Value
+					}
+				}
+			}
+		case reflect.Slice:
+			if seq, ok := node.(*ast.SequenceNode); ok {
+				for i, value := range seq.Values {
+					walk(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+				}
+			}
+		case reflect.Map:
+			entries, _ := mappingEntries(node)
+			for _, e := range entries {
+				walk(e.Value, t.Elem(), joinPath(path, e.Key.GetToken().Value))
+			}
+		}
+	}
+	walk(root, reflect.TypeFor[CLI](), "")
+}
+
+// textNode returns the string node of a text, which is the value of literal blocks.
+func textNode(node ast.Node) *ast.StringNode {
+	switch n := node.(type) {
+	case *ast.StringNode:
+		return n
+	case *ast.LiteralNode:
+		return n.Value
+	}
+	return nil
+}
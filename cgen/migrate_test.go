@@ -0,0 +1,193 @@
+package cgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// interpolatedHash returns the hash of the description once its variables are interpolated, which is
+// the one written in the generated files.
+func interpolatedHash(t *testing.T, file string, data []byte) string {
+	t.Helper()
+	spec, err := ParseSpec(file, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds := spec.Interpolate(false); len(ds) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", ds)
+	}
+	return SpecHash(spec.CLI)
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		changes []string
+	}{
+		{
+			"no references",
+			"name: cli\nshort-description: A tool\n",
+			"spec-version: 2\nname: cli\nshort-description: A tool\n",
+			[]string{"spec-version"},
+		},
+		{
+			"references",
+			"name: cli\nshort-description: Uses ${HOME}\ncommands:\n  - name: add\n    example: echo ${PATH}\n    arguments:\n      - name: file\n        completion:\n          type: function\n          bash: echo ${PATH}\n",
+			"spec-version: 2\nname: cli\nshort-description: Uses $${HOME}\ncommands:\n  - name: add\n    example: echo $${PATH}\n    arguments:\n      - name: file\n        completion:\n          type: function\n          bash: echo ${PATH}\n",
+			[]string{"short-description", "commands[0].example", "spec-version"},
+		},
+		{
+			"explicit version 1",
+			"# The tool\nspec-version: 1\nname: cli\nlong-description: Reads ${FILE}\n",
+			"# The tool\nspec-version: 2\nname: cli\nlong-description: Reads $${FILE}\n",
+			[]string{"long-description", "spec-version"},
+		},
+		{
+			"comment above the first key",
+			"# The tool\nname: cli\n",
+			"# The tool\nspec-version: 2\nname: cli\n",
+			[]string{"spec-version"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec("cli.yml", []byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			migrated, changes, err := spec.Migrate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(migrated) != tt.want {
+				t.Errorf("Migrate() =\n%s\nwant\n%s", migrated, tt.want)
+			}
+			paths := []string{}
+			for _, c := range changes {
+				paths = append(paths, c.Path)
+			}
+			if !slices.Equal(paths, tt.changes) {
+				t.Errorf("changes = %v, want %v", changes, tt.changes)
+			}
+			if got, want := interpolatedHash(t, "cli.yml", migrated), interpolatedHash(t, "cli.yml", []byte(tt.in)); got != want {
+				t.Errorf("hash = %s after migrating, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateCurrentVersion(t *testing.T) {
+	in := "spec-version: 2\nname:   cli\n"
+	spec, err := ParseSpec("cli.yml", []byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, changes, err := spec.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(migrated) != in || len(changes) != 0 {
+		t.Errorf("Migrate() = %q, %v, want the description as it is and no changes", migrated, changes)
+	}
+
+	spec, err = ParseSpecFormat("cli.json", []byte(`{"name": "cli"}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := spec.Migrate(); err == nil {
+		t.Error("Migrate() of a JSON description succeeded, want an error")
+	}
+}
+
+// TestMigrateTestCases checks that migrating the descriptions of the test cases does not change what
+// they describe.
+func TestMigrateTestCases(t *testing.T) {
+	specs, err := filepath.Glob(filepath.Join("..", "test", "*", "cli.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range specs {
+		t.Run(filepath.Base(filepath.Dir(path)), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec, err := ParseSpec(path, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			migrated, _, err := spec.Migrate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := interpolatedHash(t, path, migrated), interpolatedHash(t, path, data); got != want {
+				t.Errorf("hash = %s after migrating, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSpecVersionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		position string
+	}{
+		{"newer version", "spec-version: 3\nname: cli\n", "1:15"},
+		{"invalid version", "spec-version: two\nname: cli\n", "1:15"},
+		{"zero", "spec-version: 0\nname: cli\n", "1:15"},
+		{"variables in version 1", "name: cli\nvars:\n  docs: https://example.com\n", "2:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec("cli.yml", []byte(tt.in))
+			ds := specDiagnostics(t, err)
+			if len(ds) != 1 || ds[0].Rule != "unsupported-spec-version" {
+				t.Fatalf("diagnostics = %v, want one unsupported-spec-version", ds)
+			}
+			if p := ds[0].Position; p == nil || fmt.Sprintf("%d:%d", p.Line, p.Column) != tt.position {
+				t.Errorf("position = %v, want %s", p, tt.position)
+			}
+		})
+	}
+}
+
+func TestMissingSpecVersionWarning(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		paths []string
+	}{
+		{"no references", "name: cli\nshort-description: A tool\n", nil},
+		{"reference without spec-version", "name: cli\nshort-description: Uses ${HOME}\n", []string{"short-description"}},
+		{"reference in completion code", "name: cli\narguments:\n  - name: file\n    completion:\n      type: function\n      bash: echo ${PATH}\n", nil},
+		{"explicit version 1", "spec-version: 1\nname: cli\nshort-description: Uses ${HOME}\n", nil},
+		{"version 2", "spec-version: 2\nname: cli\nshort-description: Uses ${HOME}\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseSpec("cli.yml", []byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec.Interpolate(false)
+			paths := []string{}
+			for _, d := range spec.Lint() {
+				if d.Rule == "missing-spec-version" {
+					paths = append(paths, d.Path)
+					if d.Position == nil || d.Severity != SeverityWarning {
+						t.Errorf("diagnostic = %v, want a located warning", d)
+					}
+				}
+			}
+			if !slices.Equal(paths, tt.paths) {
+				t.Errorf("missing-spec-version at %v, want %v", paths, tt.paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package cgen
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+)
+
+// overlay is a file of changes to a description, like the commands of an edition of the tool.
+type overlay struct {
+	// Changes to the description, applied in order.
+	Operations []overlayOperation `yaml:"operations" validate:"required"`
+}
+
+// overlayOperation adds, replaces or deletes a command or an argument.
+type overlayOperation struct {
+	// One of "add", "replace" or "delete".
+	Op string `yaml:"op" validate:"required,oneof=add replace delete"`
+
+	// Path of the list of commands or arguments to add to, like commands/remote/arguments, or of the
+	// command or argument to replace or delete, like commands/remote/arguments/verbose.
+	Path string `yaml:"path" validate:"required"`
+
+	// The command or argument to add or to replace with, or a list of them.
+	Value any `yaml:"value"`
+}
+
+// applyOverlay reads the overlay file at path and applies its operations to the description.
+func (s *Spec) applyOverlay(path string, ds *[]Diagnostic) {
+	root, err := s.parseIncluded(path)
+	if err != nil {
+		if d, ok := err.(*DiagnosticError); ok {
+			*ds = append(*ds, d.Diagnostics...)
+		} else {
+			*ds = append(*ds, Diagnostic{Severity: SeverityError, Rule: "overlay-not-found", Message: err.Error()})
+		}
+		return
+	}
+
+	checked := []Diagnostic{}
+	s.check(root, reflect.TypeFor[overlay](), "", &checked)
+	for i := range checked {
+		if checked[i].Position == nil {
+			s.locate(&checked[i], tokenIn(root, checked[i].Path))
+		}
+	}
+	*ds = append(*ds, checked...)
+	if HasErrors(checked) {
+		return
+	}
+
+	var o overlay
+	if err := yaml.NodeToValue(root, &o, yaml.Strict()); err != nil {
+		*ds = append(*ds, s.decodeDiagnostic(root, err))
+		return
+	}
+
+	if len(o.Operations) == 0 {
+		return
+	}
+	entry := mappingEntry(root, "operations")
+	seq, ok := unwrapNode(entry.Value).(*ast.SequenceNode)
+	if !ok {
+		d := Diagnostic{Severity: SeverityError, Rule: "invalid-value", Path: "operations", Message: "operations must be a list"}
+		s.locate(&d, entry.Value.GetToken())
+		*ds = append(*ds, d)
+		return
+	}
+
+	abs, _ := filepath.Abs(path)
+	for i, op := range o.Operations {
+		var value []ast.Node
+		if entry := mappingEntry(seq.Values[i], "value"); entry != nil {
+			value = []ast.Node{entry.Value}
+			if values, ok := unwrapNode(entry.Value).(*ast.SequenceNode); ok {
+				value = values.Values
+			}
+			value = s.expandItems(value, filepath.Dir(path), []string{abs}, ds)
+		}
+		s.applyOperation(op.Op, op.Path, mappingEntry(seq.Values[i], "path").Value.GetToken(), value, ds)
+	}
+}
+
+// applyOperation adds value to the list at path, replaces the item at path by value or deletes it.
+// tk is the token of the path, where problems are reported.
+func (s *Spec) applyOperation(op, path string, tk *token.Token, value []ast.Node, ds *[]Diagnostic) {
+	fail := func(format string, args ...any) {
+		d := Diagnostic{Severity: SeverityError, Rule: "overlay-path-not-found", Message: fmt.Sprintf(format, args...)}
+		s.locate(&d, tk)
+		*ds = append(*ds, d)
+	}
+	if op != "delete" && len(value) == 0 {
+		d := Diagnostic{Severity: SeverityError, Rule: "missing-field", Message: fmt.Sprintf("missing value to %s", op)}
+		s.locate(&d, tk)
+		*ds = append(*ds, d)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if op == "add" && len(segments)%2 == 0 {
+		fail("the path of an addition must end with commands or arguments")
+		return
+	} else if op != "add" && len(segments)%2 == 1 {
+		fail("the path of a %s must end with the name of a command or argument", op)
+		return
+	}
+
+	node := s.root
+	for i := 0; i < len(segments); i += 2 {
+		kind := segments[i]
+		if !slices.Contains(includeKeys, kind) {
+			fail("%q is not commands or arguments", kind)
+			return
+		}
+
+		entry := mappingEntry(node, kind)
+		if entry == nil && i+1 == len(segments) {
+			// Adding to a list that does not exist yet.
+			m, ok := unwrapNode(node).(*ast.MappingNode)
+			if !ok {
+				fail("cannot add %s to %s", kind, strings.Join(segments[:i], "/"))
+				return
+			}
+			entry = s.newListEntry(kind, tk)
+			m.Values = append(m.Values, entry)
+		} else if entry == nil {
+			fail("there are no %s in %s", kind, strings.Join(append([]string{"the description"}, segments[:i]...), "/"))
+			return
+		}
+		list, ok := unwrapNode(entry.Value).(*ast.SequenceNode)
+		if !ok {
+			if _, null := unwrapNode(entry.Value).(*ast.NullNode); !null || i+1 < len(segments) {
+				fail("%s is not a list", strings.Join(segments[:i+1], "/"))
+				return
+			}
+			entry.Value = s.newListEntry(kind, tk).Value
+			list = entry.Value.(*ast.SequenceNode)
+		}
+
+		if i+1 == len(segments) {
+			list.Values = append(list.Values, value...)
+			return
+		}
+
+		name := segments[i+1]
+		index := slices.IndexFunc(list.Values, func(item ast.Node) bool { return itemNamed(item, kind, name) })
+		if index < 0 {
+			fail("there is no %s named %q in %s", strings.TrimSuffix(kind, "s"), name, strings.Join(segments[:i+1], "/"))
+			return
+		}
+		if i+2 == len(segments) {
+			rest := slices.Clone(list.Values[index+1:])
+			list.Values = list.Values[:index]
+			if op == "replace" {
+				list.Values = append(list.Values, value...)
+			}
+			list.Values = append(list.Values, rest...)
+			return
+		}
+		node = list.Values[index]
+	}
+}
+
+// itemNamed reports whether a command is named, or aliased, name, or whether an argument has the name
+// or short name name.
+func itemNamed(item ast.Node, kind, name string) bool {
+	keys := []string{"name", "aliases"}
+	if kind == "arguments" {
+		keys = []string{"name", "short-name"}
+	}
+	for _, key := range keys {
+		entry := mappingEntry(item, key)
+		if entry == nil {
+			continue
+		}
+		if aliases, ok := unwrapNode(entry.Value).(*ast.SequenceNode); ok {
+			if slices.ContainsFunc(aliases.Values, func(alias ast.Node) bool { return alias.GetToken().Value == name }) {
+				return true
+			}
+		} else if entry.Value.GetToken().Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newListEntry returns an entry of an empty list with the given key, whose tokens are located at tk.
+func (s *Spec) newListEntry(key string, tk *token.Token) *ast.MappingValueNode {
+	keyToken := token.String(key, key, tk.Position)
+	valueToken := token.New("-", "-", tk.Position)
+	if src := s.sources[tk]; src != nil {
+		s.sources[keyToken] = src
+		s.sources[valueToken] = src
+	}
+	return ast.MappingValue(token.MappingValue(tk.Position), ast.String(keyToken), ast.Sequence(valueToken, false))
+}
@@ -0,0 +1,184 @@
+package cgen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes the files, by name, in a temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// specDiagnostics returns the diagnostics of the error of ParseSpecFormat, failing the test if it is
+// of another kind.
+func specDiagnostics(t *testing.T, err error) []Diagnostic {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var diagnostics *DiagnosticError
+	if !errors.As(err, &diagnostics) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return diagnostics.Diagnostics
+}
+
+func TestOverlayWithoutOperations(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+		rule    string
+	}{
+		{"null", "operations:\n", ""},
+		{"explicit null", "operations: ~\n", ""},
+		{"empty list", "operations: []\n", ""},
+		{"mapping", "operations: {op: add}\n", "decode-error"},
+		{"scalar", "operations: 3\n", "decode-error"},
+		{"missing", "{}\n", "missing-field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"overlay.yml": tt.overlay})
+			spec, err := ParseSpecFormat("cli.yml", []byte("name: tool\n"), "yaml", filepath.Join(dir, "overlay.yml"))
+			ds := specDiagnostics(t, err)
+			if tt.rule == "" {
+				if len(ds) > 0 {
+					t.Fatalf("unexpected diagnostics: %v", ds)
+				}
+				if spec.CLI.Name != "tool" {
+					t.Errorf("name = %q, want tool", spec.CLI.Name)
+				}
+				return
+			}
+			if len(ds) != 1 || ds[0].Rule != tt.rule {
+				t.Fatalf("diagnostics = %v, want one %s", ds, tt.rule)
+			}
+			if tt.rule != "missing-field" && (ds[0].Position == nil || ds[0].Position.Line != 1 || ds[0].Position.Column != 13) {
+				t.Errorf("position = %v, want 1:13", ds[0].Position)
+			}
+		})
+	}
+}
+
+const overlayBase = `name: cli
+arguments:
+  - {named: true, name: verbose, short-name: v}
+commands:
+  - name: remote
+    aliases: [r]
+    commands:
+      - name: add
+      - name: remove
+  - name: status
+`
+
+func TestOverlayOperations(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+
+		// The description that the overlay turns overlayBase into.
+		want string
+	}{
+		{
+			"add a command",
+			"operations:\n  - {op: add, path: commands, value: {name: log}}\n",
+			"name: cli\narguments:\n  - {named: true, name: verbose, short-name: v}\n" +
+				"commands:\n  - {name: remote, aliases: [r], commands: [{name: add}, {name: remove}]}\n  - name: status\n  - name: log\n",
+		},
+		{
+			"add a list to a nested command by alias",
+			"operations:\n  - {op: add, path: commands/r/commands/add/arguments, value: [{name: url}, {name: dir}]}\n",
+			"name: cli\narguments:\n  - {named: true, name: verbose, short-name: v}\n" +
+				"commands:\n  - {name: remote, aliases: [r], commands: [{name: add, arguments: [{name: url}, {name: dir}]}, {name: remove}]}\n  - name: status\n",
+		},
+		{
+			"replace an argument by short name",
+			"operations:\n  - {op: replace, path: arguments/v, value: {named: true, name: quiet}}\n",
+			"name: cli\narguments:\n  - {named: true, name: quiet}\n" +
+				"commands:\n  - {name: remote, aliases: [r], commands: [{name: add}, {name: remove}]}\n  - name: status\n",
+		},
+		{
+			"delete a subcommand",
+			"operations:\n  - {op: delete, path: commands/remote/commands/add}\n",
+			"name: cli\narguments:\n  - {named: true, name: verbose, short-name: v}\n" +
+				"commands:\n  - {name: remote, aliases: [r], commands: [{name: remove}]}\n  - name: status\n",
+		},
+		{
+			"operations in order",
+			"operations:\n  - {op: delete, path: commands/status}\n  - {op: add, path: commands, value: {name: status, short-description: Shows}}\n",
+			"name: cli\narguments:\n  - {named: true, name: verbose, short-name: v}\n" +
+				"commands:\n  - {name: remote, aliases: [r], commands: [{name: add}, {name: remove}]}\n  - {name: status, short-description: Shows}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"overlay.yml": tt.overlay})
+			spec, err := ParseSpecFormat("cli.yml", []byte(overlayBase), "yaml", filepath.Join(dir, "overlay.yml"))
+			if ds := specDiagnostics(t, err); len(ds) > 0 {
+				t.Fatalf("unexpected diagnostics: %v", ds)
+			}
+			want, err := ParseSpec("want.yml", []byte(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if SpecHash(spec.CLI) != SpecHash(want.CLI) {
+				t.Errorf("the overlay does not give\n%s", tt.want)
+			}
+		})
+	}
+}
+
+func TestOverlayErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		overlay  string
+		rule     string
+		position string
+	}{
+		{"unknown command", "operations:\n  - {op: delete, path: commands/log}\n", "overlay-path-not-found", "2:24"},
+		{"unknown nested command", "operations:\n  - op: delete\n    path: commands/remote/commands/rename\n", "overlay-path-not-found", "3:11"},
+		{"addition to an item", "operations:\n  - {op: add, path: commands/status, value: {name: log}}\n", "overlay-path-not-found", "2:21"},
+		{"replacement of a list", "operations:\n  - {op: replace, path: commands, value: {name: log}}\n", "overlay-path-not-found", "2:25"},
+		{"unknown list", "operations:\n  - {op: add, path: options, value: {name: log}}\n", "overlay-path-not-found", "2:21"},
+		{"missing value", "operations:\n  - {op: add, path: commands}\n", "missing-field", "2:21"},
+		{"unknown operation", "operations:\n  - {op: move, path: commands/status}\n", "invalid-value", "2:10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"overlay.yml": tt.overlay})
+			_, err := ParseSpecFormat("cli.yml", []byte(overlayBase), "yaml", filepath.Join(dir, "overlay.yml"))
+			ds := specDiagnostics(t, err)
+			if len(ds) != 1 || ds[0].Rule != tt.rule {
+				t.Fatalf("diagnostics = %v, want one %s", ds, tt.rule)
+			}
+			p := ds[0].Position
+			if p == nil {
+				t.Fatal("the diagnostic has no position")
+			}
+			if got := fmt.Sprintf("%s:%d:%d", filepath.Base(p.File), p.Line, p.Column); got != "overlay.yml:"+tt.position {
+				t.Errorf("position = %s, want overlay.yml:%s", got, tt.position)
+			}
+		})
+	}
+
+	_, err := ParseSpecFormat("cli.yml", []byte(overlayBase), "yaml", filepath.Join(t.TempDir(), "missing.yml"))
+	if ds := specDiagnostics(t, err); len(ds) != 1 || ds[0].Rule != "overlay-not-found" {
+		t.Errorf("diagnostics = %v, want one overlay-not-found", ds)
+	}
+}
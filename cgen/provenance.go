@@ -0,0 +1,80 @@
+package cgen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpecHash returns the SHA-256 of the normalized description, which is the same for files that
+// only differ in formatting, comments or defaulted values.
+func SpecHash(cli *CLI) string {
+	// Decode the description into generic values and drop the empty ones, so that empty lists hash
+	// like missing ones.
+	data, _ := json.Marshal(cli)
+	var v any
+	json.Unmarshal(data, &v)
+	data, _ = json.Marshal(pruneEmpty(v))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// pruneEmpty removes the null values and empty lists from the objects in v.
+func pruneEmpty(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if list, ok := value.([]any); value == nil || ok && len(list) == 0 {
+				delete(v, key)
+			} else {
+				v[key] = pruneEmpty(value)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = pruneEmpty(v[i])
+		}
+	}
+	return v
+}
+
+// BuildDate returns the date stamped in man pages: the date of the description if set, otherwise
+// SOURCE_DATE_EPOCH if set, otherwise the current date.
+func BuildDate(cli *CLI) (string, error) {
+	if cli.Date != "" {
+		return cli.Date, nil
+	}
+	if epoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+		}
+		return time.Unix(seconds, 0).UTC().Format("02-Jan-2006"), nil
+	}
+	return time.Now().Format("02-Jan-2006"), nil
+}
+
+// writeHeader writes the notice that starts every generated file, with each line prefixed by the
+// comment marker of the output format.
+func writeHeader(w io.Writer, cli *CLI, comment string) error {
+	from := ""
+	if cli.Source != "" {
+		from = " from " + cli.Source
+	}
+	lines := []string{
+		fmt.Sprintf("Generated by cgen v%s%s, do not edit.", strings.TrimSpace(Version), from),
+		fmt.Sprintf("Spec hash: sha256:%s", SpecHash(cli)),
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(w, "%s %s\n", comment, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package cgen
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+)
+
+// Field describes a key of the description files.
+type Field struct {
+	// Key in the description file.
+	Name string
+
+	// Type of the value, like string, boolean or list of Argument.
+	Type string
+
+	// Documentation of the field, from the comment of its declaration.
+	Description string
+
+	// Accepted values, if limited.
+	Values []string
+
+	// Value used when the key is missing, if not the zero value.
+	Default any
+}
+
+var pathIndex = regexp.MustCompile(`\[\d+\]`)
+
+// LookupField returns the field at a path like commands[0].arguments[1].name. Indexes are optional.
+func LookupField(path string) (Field, bool) {
+	parent, key := "", path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		parent, key = path[:i], path[i+1:]
+	}
+	key = pathIndex.ReplaceAllString(key, "")
+	for _, f := range FieldsAt(parent) {
+		if f.Name == key {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// FieldsAt returns the fields accepted in the mapping at path, like commands[0].arguments[1]. Indexes
+// are optional, and the keys of maps, like the names in definitions.arguments.verbose, are skipped.
+// It returns nil if the path does not lead to a mapping.
+func FieldsAt(path string) []Field {
+	t := reflect.TypeFor[CLI]()
+	if path = pathIndex.ReplaceAllString(path, ""); path != "" {
+		inMap := false
+		for key := range strings.SplitSeq(path, ".") {
+			if inMap {
+				inMap = false
+				continue
+			}
+			i := slices.IndexFunc(specFields(t), func(f specField) bool { return f.Name == key })
+			if i < 0 {
+				return nil
+			}
+			t = specFields(t)[i].Type
+			inMap = t.Kind() == reflect.Map
+			t = elemType(t)
+			if t.Kind() != reflect.Struct {
+				return nil
+			}
+		}
+		if inMap {
+			return nil
+		}
+	}
+
+	defaults := defaultValue(t)
+	fields := []Field{}
+	for _, f := range specFields(t) {
+		field := Field{
+			Name:        f.Name,
+			Type:        typeName(f.Type),
+			Description: typeDocs()[t.Name()+"."+t.Field(f.Index).Name],
+			Values:      f.OneOf,
+		}
+		if v := defaults.Field(f.Index); !v.IsZero() && v.Kind() != reflect.Struct {
+			field.Default = v.Interface()
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	case reflect.Slice:
+		return "list of " + typeName(t.Elem())
+	case reflect.Map:
+		return "map of " + typeName(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	}
+	return t.Name()
+}
+
+// PathAt returns the path of the innermost key whose entry contains the given position, with lines
+// and columns starting at 1. It returns an empty string if the position is outside every entry.
+func (s *Spec) PathAt(line, column int) string {
+	best := ""
+	var walk func(node ast.Node, path string)
+	walk = func(node ast.Node, path string) {
+		node = unwrapNode(node)
+		if entries, ok := mappingEntries(node); ok {
+			for _, entry := range entries {
+				if s.entryContains(entry.Key.GetToken(), line, column) {
+					best = joinPath(path, entry.Key.GetToken().Value)
+					walk(entry.Value, best)
+				}
+			}
+		}
+		if seq, ok := node.(*ast.SequenceNode); ok {
+			for i, value := range seq.Values {
+				walk(value, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(s.root, "")
+	return best
+}
+
+// entryContains reports whether the entry whose key is tk contains the position: it is on the line
+// of the key, after its start, or on a later line that is indented further than the key, as are all
+// lines between.
+func (s *Spec) entryContains(tk *token.Token, line, column int) bool {
+	if tk == nil || tk.Position == nil || s.Format == "toml" || s.sources[tk] != nil {
+		return false
+	}
+	start := tk.Position
+	if line < start.Line || line == start.Line && column < start.Column {
+		return false
+	}
+	for i := start.Line + 1; i <= line && i <= len(s.lines); i++ {
+		text := s.lines[i-1]
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		// A sequence may be indented as much as the key that holds it.
+		indent := len(text) - len(trimmed)
+		if indent < start.Column-1 || indent == start.Column-1 && !strings.HasPrefix(trimmed, "- ") {
+			return false
+		}
+	}
+	return true
+}
+
+// DefinitionAt returns where the anchor of the alias at the given position is defined, the files
+// matched by the include at the given position, or the definitions named by the use at the given
+// position. It returns nil if there is none of them there.
+func (s *Spec) DefinitionAt(line, column int) []Position {
+	if s.original == nil {
+		return nil
+	}
+
+	var alias *ast.AliasNode
+	var include, use *ast.StringNode
+	anchors := map[string]*token.Token{}
+	within := func(tk *token.Token, end *token.Token) bool {
+		return tk != nil && end != nil && tk.Position.Line == line && column >= tk.Position.Column &&
+			column <= end.Position.Column+len(end.Value)
+	}
+	ast.Walk(visitorFunc(func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			anchors[n.Name.GetToken().Value] = n.Name.GetToken()
+		case *ast.AliasNode:
+			if within(n.Start, n.Value.GetToken()) {
+				alias = n
+			}
+		case *ast.MappingValueNode:
+			if value, ok := n.Value.(*ast.StringNode); ok && n.Key.GetToken().Value == "include" && within(value.GetToken(), value.GetToken()) {
+				include = value
+			}
+			if value, ok := n.Value.(*ast.StringNode); ok && n.Key.GetToken().Value == "use" && within(value.GetToken(), value.GetToken()) {
+				use = value
+			}
+		}
+	}), s.original)
+
+	if include != nil {
+		pattern := include.Value
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(s.File), pattern)
+		}
+		paths, _ := filepath.Glob(pattern)
+		positions := []Position{}
+		for _, path := range paths {
+			positions = append(positions, Position{File: path, Line: 1, Column: 1})
+		}
+		return positions
+	}
+	if use != nil {
+		positions := []Position{}
+		definitions := mappingEntry(s.original, "definitions")
+		for _, kind := range definitionKinds {
+			if definitions == nil {
+				break
+			}
+			if named := mappingEntry(definitions.Value, kind); named != nil {
+				if e := mappingEntry(named.Value, use.Value); e != nil {
+					tk := e.Key.GetToken()
+					positions = append(positions, Position{File: s.File, Line: tk.Position.Line, Column: tk.Position.Column, Length: len(tk.Value)})
+				}
+			}
+		}
+		return positions
+	}
+	if alias == nil {
+		return nil
+	}
+
+	tk := anchors[alias.Value.GetToken().Value]
+	if tk == nil {
+		return nil
+	}
+	return []Position{{File: s.File, Line: tk.Position.Line, Column: tk.Position.Column, Length: len(tk.Value)}}
+}
+
+type visitorFunc func(ast.Node)
+
+func (f visitorFunc) Visit(node ast.Node) ast.Visitor {
+	f(node)
+	return f
+}
+
+// ArgumentLines returns the lines that the generator writes for the argument at path, like
+// commands[0].arguments[1] or arguments[2]. They are the lines that change in the output when the
+// argument is removed, with "..." between the lines that are not consecutive.
+func ArgumentLines(g Generator, cli *CLI, path string) ([]string, error) {
+	without, err := withoutArgument(*cli, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var full, reduced bytes.Buffer
+	if err := g.Write(cli, &full); err != nil {
+		return nil, err
+	}
+	if err := g.Write(&without, &reduced); err != nil {
+		return nil, err
+	}
+
+	// The header has the hash of the description, which changes when the argument is removed.
+	hash := SpecHash(cli)
+	output := splitOutput(full.String())
+	lines := []string{}
+	previous := -1
+	for _, i := range removedLines(output, splitOutput(reduced.String())) {
+		line := output[i]
+		if strings.Contains(line, hash) {
+			continue
+		}
+		if previous >= 0 && i != previous+1 {
+			lines = append(lines, "...")
+		}
+		lines = append(lines, line)
+		previous = i
+	}
+	return lines, nil
+}
+
+// removedLines returns the indexes of the lines of a that are not in the longest common subsequence
+// of a and b.
+func removedLines(a, b []string) []int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	removed := []int{}
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || lcs[i+1][j] >= lcs[i][j+1]:
+			removed = append(removed, i)
+			i++
+		default:
+			j++
+		}
+	}
+	return removed
+}
+
+func splitOutput(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// withoutArgument returns a copy of cli without the argument at path. The slices along the path are
+// copied, so cli is not modified.
+func withoutArgument(cli CLI, path string) (CLI, error) {
+	parts := strings.Split(path, ".")
+	last, ok := strings.CutPrefix(parts[len(parts)-1], "arguments[")
+	index, err := strconv.Atoi(strings.TrimSuffix(last, "]"))
+	if !ok || err != nil {
+		return cli, fmt.Errorf("%s is not the path of an argument", path)
+	}
+
+	arguments, commands := &cli.Arguments, &cli.Commands
+	for _, part := range parts[:len(parts)-1] {
+		rest, ok := strings.CutPrefix(part, "commands[")
+		i, err := strconv.Atoi(strings.TrimSuffix(rest, "]"))
+		if !ok || err != nil || i < 0 || i >= len(*commands) {
+			return cli, fmt.Errorf("%s is not the path of an argument", path)
+		}
+		*commands = slices.Clone(*commands)
+		cmd := &(*commands)[i]
+		arguments, commands = &cmd.Arguments, &cmd.Subcommands
+	}
+	if index < 0 || index >= len(*arguments) {
+		return cli, fmt.Errorf("%s is not the path of an argument", path)
+	}
+	*arguments = slices.Delete(slices.Clone(*arguments), index, index+1)
+	return cli, nil
+}
@@ -0,0 +1,53 @@
+package cgen
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestArgumentLines(t *testing.T) {
+	spec, err := ParseSpec("cli.yml", []byte(`name: cli
+arguments:
+  - {named: true, name: verbose}
+commands:
+  - name: add
+    arguments:
+      - {named: true, name: force}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, _ := LookupGenerator("fish")
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"arguments[0]", "complete -c cli -l verbose", true},
+		{"commands[0].arguments[0]", "complete -c cli -n '__fish_seen_subcommand_from add' -l force", true},
+		{"arguments[1]", "", false},
+		{"arguments[-1]", "", false},
+		{"commands[-1].arguments[0]", "", false},
+		{"commands[1].arguments[0]", "", false},
+		{"commands[0].arguments[-1]", "", false},
+		{"commands[0]", "", false},
+		{"options[0]", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			lines, err := ArgumentLines(g, spec.CLI, tt.path)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("ArgumentLines() = %v, want an error", lines)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(lines, tt.want) {
+				t.Errorf("ArgumentLines() = %q, want it to contain %q", lines, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package cgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSARIFRuleIndexes(t *testing.T) {
+	ds := []Diagnostic{}
+	for _, r := range Rules() {
+		ds = append(ds, Diagnostic{Severity: r.Severity, Rule: r.ID, Message: r.Description})
+	}
+	ds = append(ds, Diagnostic{Severity: SeverityError, Rule: "not-a-rule", Message: "unknown"})
+
+	var b bytes.Buffer
+	if err := WriteSARIF(&b, ds); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex *int   `json:"ruleIndex"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	run := log.Runs[0]
+	for _, result := range run.Results {
+		if result.RuleID == "not-a-rule" {
+			if result.RuleIndex != nil {
+				t.Errorf("the unknown rule has ruleIndex %d, want none", *result.RuleIndex)
+			}
+			continue
+		}
+		if result.RuleIndex == nil {
+			t.Errorf("%s has no ruleIndex", result.RuleID)
+		} else if id := run.Tool.Driver.Rules[*result.RuleIndex].ID; id != result.RuleID {
+			t.Errorf("the ruleIndex of %s points at %s", result.RuleID, id)
+		}
+	}
+}
+
+func TestSARIFURI(t *testing.T) {
+	tests := []struct {
+		file string
+		uri  string
+		ok   bool
+	}{
+		{"cli.yml", "cli.yml", true},
+		{"my specs/cli #1.yml", "my%20specs/cli%20%231.yml", true},
+		{"/elsewhere/cli.yml", "file:///elsewhere/cli.yml", true},
+		{"<stdin>", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		uri, ok := sarifURI(&Position{File: tt.file, Line: 1, Column: 1})
+		if uri != tt.uri || ok != tt.ok {
+			t.Errorf("sarifURI(%q) = %q, %v, want %q, %v", tt.file, uri, ok, tt.uri, tt.ok)
+		}
+	}
+}
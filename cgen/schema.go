@@ -0,0 +1,229 @@
+package cgen
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+)
+
+// The declarations of the description types, whose comments document the fields in the schema.
+//
+//go:embed types.go
+var typesSource string
+
+type jsonSchema struct {
+	Schema               string            `json:"$schema,omitempty"`
+	Ref                  string            `json:"$ref,omitempty"`
+	Title                string            `json:"title,omitempty"`
+	Description          string            `json:"description,omitempty"`
+	Type                 string            `json:"type,omitempty"`
+	Enum                 []string          `json:"enum,omitempty"`
+	Default              any               `json:"default,omitempty"`
+	Items                *jsonSchema       `json:"items,omitempty"`
+	AnyOf                []*jsonSchema     `json:"anyOf,omitempty"`
+	Properties           *schemaProperties `json:"properties,omitempty"`
+	AdditionalProperties any               `json:"additionalProperties,omitempty"`
+	Required             []string          `json:"required,omitempty"`
+	Definitions          *schemaProperties `json:"definitions,omitempty"`
+}
+
+// schemaProperties is a JSON object that keeps the order of its keys, so that the properties are
+// listed in the order of the fields.
+type schemaProperties struct {
+	names   []string
+	schemas []*jsonSchema
+}
+
+func (p *schemaProperties) add(name string, s *jsonSchema) {
+	p.names = append(p.names, name)
+	p.schemas = append(p.schemas, s)
+}
+
+func (p *schemaProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range p.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		value, err := json.Marshal(p.schemas[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// JSONSchema returns a JSON Schema (draft-07) of the description files, which editors can use to
+// validate and complete them.
+func JSONSchema() ([]byte, error) {
+	b := schemaBuilder{comments: typeDocs(), definitions: &schemaProperties{}, defined: map[reflect.Type]bool{}}
+	root := b.object(reflect.TypeFor[CLI]())
+	root.Schema = "http://json-schema.org/draft-07/schema#"
+	root.Title = "cgen CLI description"
+	root.Definitions = b.definitions
+
+	include := &jsonSchema{
+		Description:          "Items read from other files.",
+		Type:                 "object",
+		Properties:           &schemaProperties{},
+		AdditionalProperties: false,
+		Required:             []string{"include"},
+	}
+	include.Properties.add("include", &jsonSchema{
+		Description: "Path of a file, or glob pattern of files, relative to the including file. Each file holds a list of items or a single one.",
+		Type:        "string",
+	})
+	b.definitions.add("Include", include)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type schemaBuilder struct {
+	// Doc comments of the types and of their fields, by "Type" and "Type.Field".
+	comments map[string]string
+
+	definitions *schemaProperties
+	defined     map[reflect.Type]bool
+}
+
+// object returns the schema of a struct, with the defaults its UnmarshalYAML sets.
+func (b *schemaBuilder) object(t reflect.Type) *jsonSchema {
+	defaults := defaultValue(t)
+	s := &jsonSchema{
+		Description:          b.comments[t.Name()],
+		Type:                 "object",
+		Properties:           &schemaProperties{},
+		AdditionalProperties: false,
+	}
+	for _, f := range specFields(t) {
+		field := b.schema(f.Type)
+		field.Description = b.comments[t.Name()+"."+t.Field(f.Index).Name]
+		field.Enum = f.OneOf
+		if v := defaults.Field(f.Index); !v.IsZero() && v.Kind() != reflect.Struct {
+			field.Default = v.Interface()
+		}
+		if slices.Contains(includeKeys, f.Name) && field.Items != nil {
+			field.Items = &jsonSchema{AnyOf: []*jsonSchema{field.Items, {Ref: "#/definitions/Include"}}}
+		}
+		if f.Required {
+			s.Required = append(s.Required, f.Name)
+		}
+		s.Properties.add(f.Name, field)
+	}
+	return s
+}
+
+// schema returns the schema of a value of type t. Structs are defined once and referenced.
+func (b *schemaBuilder) schema(t reflect.Type) *jsonSchema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if !b.defined[t] {
+			// Reserve the definition first, so that they are listed in the order they are found.
+			b.defined[t] = true
+			i := len(b.definitions.names)
+			b.definitions.add(t.Name(), nil)
+			b.definitions.schemas[i] = b.object(t)
+		}
+		return &jsonSchema{Ref: "#/definitions/" + t.Name()}
+	}
+	return &jsonSchema{}
+}
+
+// defaultValue returns the value of type t decoded from an empty mapping, which has the defaults set
+// by its UnmarshalYAML.
+func defaultValue(t reflect.Type) reflect.Value {
+	v := reflect.New(t)
+	yaml.Unmarshal([]byte("{}"), v.Interface())
+	return v.Elem()
+}
+
+var typeDocs = sync.OnceValue(typeComments)
+
+// typeComments returns the doc comments of the types declared in types.go and of their fields.
+func typeComments() map[string]string {
+	comments := map[string]string{}
+	f, err := parser.ParseFile(token.NewFileSet(), "types.go", typesSource, parser.ParseComments)
+	if err != nil {
+		return comments
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			doc := ts.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			comments[ts.Name.Name] = commentText(doc)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range st.Fields.List {
+				for _, name := range field.Names {
+					comments[ts.Name.Name+"."+name.Name] = commentText(field.Doc)
+				}
+			}
+		}
+	}
+	return comments
+}
+
+// commentText joins the lines of a comment that continue a sentence, keeping the lines that start
+// lists or new sentences.
+func commentText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(doc.Text()), "\n")
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			if strings.HasSuffix(prev, ".") || strings.HasSuffix(prev, ":") || strings.Contains(line, ": ") {
+				b.WriteString("\n")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
@@ -0,0 +1,460 @@
+package cgen
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+)
+
+// Spec is a CLI description decoded from a file. It remembers where each value came from, so that
+// diagnostics can point at the offending line.
+type Spec struct {
+	CLI *CLI
+
+	// Name of the description file, as shown in diagnostics.
+	File string
+
+	// Paths of every file the description was read from, starting with File.
+	Files []string
+
+	// Format of the description file, one of SpecFormats.
+	Format string
+
+	lines []string
+
+	// Tree of the description, with the included items in place of the include entries and the
+	// overlays applied.
+	root ast.Node
+
+	// Tree of the description file itself.
+	original ast.Node
+
+	// Files of the tokens that come from included files.
+	sources map[*token.Token]*specSource
+
+	// Changes made to the tree to upgrade it from an older version of the format.
+	changes []Change
+}
+
+// specSource is a file a description was read from.
+type specSource struct {
+	file   string
+	format string
+	lines  []string
+}
+
+// sourceOf returns the file of a token of the tree.
+func (s *Spec) sourceOf(tk *token.Token) specSource {
+	if src, ok := s.sources[tk]; ok {
+		return *src
+	}
+	return specSource{s.File, s.Format, s.lines}
+}
+
+// ParseSpec decodes the CLI description in data, in the format given by the extension of the file
+// name. The file name is otherwise only used in diagnostics. If the description cannot be decoded,
+// the error is a *DiagnosticError.
+func ParseSpec(file string, data []byte) (*Spec, error) {
+	return ParseSpecFormat(file, data, SpecFormat(file))
+}
+
+// ParseSpecFormat is like ParseSpec, but the format of data is given explicitly. The overlay files, if
+// any, are applied in order on top of the description.
+func ParseSpecFormat(file string, data []byte, format string, overlays ...string) (*Spec, error) {
+	s := &Spec{File: file, Files: []string{file}, Format: format, lines: strings.Split(string(data), "\n")}
+
+	if err := s.parse(data); err != nil {
+		return nil, err
+	}
+
+	ds := []Diagnostic{}
+	version := s.specVersion(s.root, &ds)
+	s.checkVersionKeys(s.root, version, &ds)
+	abs, _ := filepath.Abs(file)
+	s.expandIncludes(s.root, filepath.Dir(file), []string{abs}, &ds)
+	for _, overlay := range overlays {
+		s.applyOverlay(overlay, &ds)
+	}
+	s.resolveDefinitions(&ds)
+	if HasErrors(ds) {
+		return nil, &DiagnosticError{Diagnostics: ds}
+	}
+	s.changes = s.upgrade(s.root, version)
+
+	s.check(s.root, reflect.TypeFor[CLI](), "", &ds)
+	if HasErrors(ds) {
+		return nil, &DiagnosticError{Diagnostics: s.Annotate(ds)}
+	}
+
+	var cli CLI
+	if s.root != nil {
+		if err := yaml.NodeToValue(s.root, &cli, yaml.Validator(validator.New()), yaml.Strict()); err != nil {
+			return nil, &DiagnosticError{Diagnostics: []Diagnostic{s.decodeDiagnostic(s.root, err)}}
+		}
+	}
+	cli.Source = filepath.Base(file)
+	s.CLI = &cli
+
+	return s, nil
+}
+
+// parse parses data, in the format of the description, into its tree.
+func (s *Spec) parse(data []byte) error {
+	switch s.Format {
+	case "yaml":
+		return s.parseYAML(data)
+	case "json":
+		return s.parseJSON(data)
+	case "toml":
+		return s.parseTOML(data)
+	}
+	return fmt.Errorf("unknown format %q. Available: %s", s.Format, strings.Join(SpecFormats, ", "))
+}
+
+// Bytes returns the content of the description file.
+func (s *Spec) Bytes() []byte {
+	return []byte(strings.Join(s.lines, "\n"))
+}
+
+// Validate runs Validate on the description and returns its errors located in the file.
+func (s *Spec) Validate() []Diagnostic {
+	if err, ok := Validate(s.CLI).(*ValidationError); ok {
+		return s.Annotate(err.Diagnostics())
+	}
+	return nil
+}
+
+// Lint runs Lint on the description and returns its diagnostics located in the file. Descriptions
+// without a spec-version are warned about where a newer version would read them differently.
+func (s *Spec) Lint() []Diagnostic {
+	ds := Lint(s.CLI)
+	if s.CLI.SpecVersion == 0 {
+		for _, c := range s.changes {
+			d := Diagnostic{Severity: SeverityWarning, Rule: "missing-spec-version", Path: c.Path, Message: migrationWarning(c.Version)}
+			s.locate(&d, c.token)
+			ds = append(ds, d)
+		}
+	}
+	return s.Annotate(ds)
+}
+
+// Interpolate runs Interpolate on the description and returns its diagnostics located in the file.
+func (s *Spec) Interpolate(env bool) []Diagnostic {
+	return s.Annotate(Interpolate(s.CLI, env))
+}
+
+// Annotate fills the position and snippet of the diagnostics that have a path but no position.
+func (s *Spec) Annotate(ds []Diagnostic) []Diagnostic {
+	for i := range ds {
+		if ds[i].Position == nil && ds[i].Path != "" {
+			s.locate(&ds[i], s.tokenAt(ds[i].Path))
+		}
+	}
+	return ds
+}
+
+func (s *Spec) locate(d *Diagnostic, tk *token.Token) {
+	// The nodes of TOML files are built from the decoded values, so their positions are meaningless.
+	src := s.sourceOf(tk)
+	if tk == nil || tk.Position == nil || src.format == "toml" {
+		return
+	}
+
+	length := len([]rune(tk.Value))
+	if tk.Type == token.DoubleQuoteType || tk.Type == token.SingleQuoteType {
+		length += 2
+	}
+	mark(d, src, tk.Position.Line, tk.Position.Column, length)
+}
+
+// mark sets the position of the diagnostic in src and the snippet of the lines it points at.
+func mark(d *Diagnostic, src specSource, line, column, length int) {
+	d.Position = &Position{
+		File:   src.file,
+		Line:   line,
+		Column: column,
+		Length: max(length, 1),
+	}
+
+	if line >= 1 && line <= len(src.lines) && column >= 1 {
+		gutter := fmt.Sprintf("%5d | ", d.Position.Line)
+		d.Snippet = gutter + src.lines[d.Position.Line-1] + "\n" +
+			strings.Repeat(" ", len(gutter)-2) + "| " +
+			strings.Repeat(" ", d.Position.Column-1) + strings.Repeat("^", d.Position.Length)
+	}
+}
+
+// decodeDiagnostic converts an error of the YAML parser or decoder of the tree under root.
+func (s *Spec) decodeDiagnostic(root ast.Node, err error) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Rule: "syntax-error", Message: err.Error()}
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) {
+		d.Message = yamlErr.GetMessage()
+		tk := yamlErr.GetToken()
+		if root != nil && tk != nil {
+			d.Rule = "decode-error"
+			d.Path = pathOf(root, "", tk)
+		}
+		s.locate(&d, tk)
+	}
+	return d
+}
+
+// check reports unknown keys, missing required keys and values not in the accepted set, which the
+// decoder would otherwise report one at a time and without suggestions.
+func (s *Spec) check(node ast.Node, t reflect.Type, path string, ds *[]Diagnostic) {
+	node = unwrapNode(node)
+	switch t.Kind() {
+	case reflect.Struct:
+		entries, ok := mappingEntries(node)
+		if !ok && node != nil {
+			return
+		}
+
+		fields := specFields(t)
+		names := make([]string, len(fields))
+		for i, f := range fields {
+			names[i] = f.Name
+		}
+
+		seen := map[string]bool{}
+		for _, entry := range entries {
+			if entry.Key.IsMergeKey() {
+				continue
+			}
+			key := entry.Key.GetToken().Value
+			fieldPath := joinPath(path, key)
+			i := slices.Index(names, key)
+			if i < 0 {
+				d := Diagnostic{
+					Severity:   SeverityError,
+					Rule:       "unknown-field",
+					Path:       fieldPath,
+					Message:    fmt.Sprintf("unknown field %q", key),
+					Suggestion: suggest(key, names),
+				}
+				s.locate(&d, entry.Key.GetToken())
+				*ds = append(*ds, d)
+				continue
+			}
+			seen[key] = true
+
+			field := fields[i]
+			value := unwrapNode(entry.Value)
+			if len(field.OneOf) > 0 && value != nil && value.Type() != ast.NullType && value.Type() != ast.AliasType {
+				v := value.GetToken().Value
+				if !slices.Contains(field.OneOf, v) {
+					d := Diagnostic{
+						Severity:   SeverityError,
+						Rule:       "invalid-value",
+						Path:       fieldPath,
+						Message:    invalidValueMessage(key, v, field.OneOf),
+						Suggestion: suggest(v, field.OneOf),
+					}
+					s.locate(&d, value.GetToken())
+					*ds = append(*ds, d)
+				}
+			}
+			s.check(entry.Value, field.Type, fieldPath, ds)
+		}
+
+		for _, f := range fields {
+			if f.Required && !seen[f.Name] {
+				d := Diagnostic{
+					Severity: SeverityError,
+					Rule:     "missing-field",
+					Path:     path,
+					Message:  fmt.Sprintf("missing required field %q", f.Name),
+				}
+				if path == "" {
+					s.locate(&d, nodeToken(node, nil))
+				}
+				*ds = append(*ds, d)
+			}
+		}
+	case reflect.Slice:
+		seq, ok := node.(*ast.SequenceNode)
+		if !ok {
+			return
+		}
+		for i, value := range seq.Values {
+			s.check(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i), ds)
+		}
+	case reflect.Map:
+		entries, _ := mappingEntries(node)
+		for _, entry := range entries {
+			s.check(entry.Value, t.Elem(), joinPath(path, entry.Key.GetToken().Value), ds)
+		}
+	}
+}
+
+// tokenAt returns the token of the value at path. If the value is a mapping or a sequence, or if it
+// is missing, the token of the closest key is returned instead.
+func (s *Spec) tokenAt(path string) *token.Token {
+	return tokenIn(s.root, path)
+}
+
+// tokenIn is like tokenAt, for the tree under node.
+func tokenIn(node ast.Node, path string) *token.Token {
+	node = unwrapNode(node)
+	var owner *token.Token
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			entry := mappingEntry(node, key)
+			if entry == nil {
+				break
+			}
+			node, owner = unwrapNode(entry.Value), entry.Key.GetToken()
+		}
+		for rest != "" {
+			idx, next, _ := strings.Cut(rest, "]")
+			rest = strings.TrimPrefix(next, "[")
+			i, err := strconv.Atoi(idx)
+			seq, ok := node.(*ast.SequenceNode)
+			if err != nil || !ok || i >= len(seq.Values) {
+				return nodeToken(node, owner)
+			}
+			node, owner = unwrapNode(seq.Values[i]), nil
+		}
+	}
+	return nodeToken(node, owner)
+}
+
+func nodeToken(node ast.Node, owner *token.Token) *token.Token {
+	if node == nil {
+		return owner
+	}
+	switch node.Type() {
+	case ast.MappingType, ast.SequenceType, ast.NullType:
+		if owner != nil {
+			return owner
+		}
+		if entries, ok := mappingEntries(node); ok && len(entries) > 0 {
+			return entries[0].Key.GetToken()
+		}
+	}
+	return node.GetToken()
+}
+
+// pathOf returns the path of the node whose key or value is at the position of tk.
+func pathOf(node ast.Node, path string, tk *token.Token) string {
+	node = unwrapNode(node)
+	if node == nil {
+		return ""
+	}
+	if entries, ok := mappingEntries(node); ok {
+		for _, entry := range entries {
+			entryPath := joinPath(path, entry.Key.GetToken().Value)
+			if samePosition(entry.Key.GetToken(), tk) || samePosition(entry.Value.GetToken(), tk) {
+				return entryPath
+			}
+			if p := pathOf(entry.Value, entryPath, tk); p != "" {
+				return p
+			}
+		}
+	}
+	if seq, ok := node.(*ast.SequenceNode); ok {
+		for i, value := range seq.Values {
+			valuePath := fmt.Sprintf("%s[%d]", path, i)
+			if samePosition(value.GetToken(), tk) {
+				return valuePath
+			}
+			if p := pathOf(value, valuePath, tk); p != "" {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func samePosition(a, b *token.Token) bool {
+	return a != nil && b != nil && a.Position != nil && b.Position != nil &&
+		a.Position.Line == b.Position.Line && a.Position.Column == b.Position.Column
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func unwrapNode(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.TagNode:
+			node = n.Value
+		default:
+			return node
+		}
+	}
+}
+
+func mappingEntries(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch n := unwrapNode(node).(type) {
+	case *ast.MappingNode:
+		return n.Values, true
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}, true
+	}
+	return nil, false
+}
+
+func mappingEntry(node ast.Node, key string) *ast.MappingValueNode {
+	entries, _ := mappingEntries(node)
+	for _, entry := range entries {
+		if entry.Key.GetToken().Value == key {
+			return entry
+		}
+	}
+	return nil
+}
+
+// specField is a field of the description file, as declared by the struct tags of CLI and of the
+// types it contains.
+type specField struct {
+	// Key in the description file.
+	Name string
+
+	// Index of the field in the struct.
+	Index int
+
+	Type     reflect.Type
+	Required bool
+
+	// Accepted values, if limited.
+	OneOf []string
+}
+
+func specFields(t reflect.Type) []specField {
+	fields := []specField{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		field := specField{Name: name, Index: i, Type: f.Type}
+		for rule := range strings.SplitSeq(f.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				field.Required = true
+			} else if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+				field.OneOf = strings.Fields(values)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
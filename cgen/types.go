@@ -1,6 +1,10 @@
 package cgen
 
+// CLI describes a tool: its metadata, global arguments and commands.
 type CLI struct {
+	// Version of the format of the description. Descriptions without it are read as version 1.
+	SpecVersion int `yaml:"spec-version" json:"-"`
+
 	// The tool's name.
 	Name string `yaml:"name" validate:"required"`
 
@@ -13,14 +17,35 @@ type CLI struct {
 	// Tool's version.
 	Version string `yaml:"version"`
 
+	// Date shown in the man pages. Defaults to SOURCE_DATE_EPOCH, or to the current date.
+	Date string `yaml:"date"`
+
 	// Global arguments.
 	Arguments []Argument `yaml:"arguments"`
 
 	// Top-level Commands
 	Commands []Command `yaml:"commands"`
+
+	// Arguments, groups of arguments and commands that others are based on.
+	Definitions Definitions `yaml:"definitions" json:"-"`
+
+	// Values of the variables referenced, as ${name}, in the texts of the tool, of its commands and of
+	// their arguments.
+	Vars map[string]string `yaml:"vars" json:"-"`
+
+	// Completion functions shared by arguments, by name.
+	Providers map[string]Provider `yaml:"providers"`
+
+	// Name of the description file, shown in the header of the generated files.
+	Source string `yaml:"-" json:"-"`
 }
 
+// Argument is a named option or a positional argument of the tool or of a command.
 type Argument struct {
+	// Name of the argument or group of arguments of the definitions this argument is based on. The
+	// other keys override those of the definition. A group is inserted in place of the argument.
+	Use string `yaml:"use,omitempty" json:"-"`
+
 	///// Completion /////
 
 	// Whether this is a named or positional argument.
@@ -30,10 +55,10 @@ type Argument struct {
 	SingleDashLong bool `yaml:"single-dash-long"`
 
 	// How to separate --long-options from their values: "space", "equal", "both"
-	LongValueSeparator string `yaml:"long-value-separator"`
+	LongValueSeparator string `yaml:"long-value-separator" validate:"oneof=space equal both"`
 
 	// How to separate short options (-v) from their values: "space", "attached", "both"
-	ShortValueSeparator string `yaml:"short-value-separator"`
+	ShortValueSeparator string `yaml:"short-value-separator" validate:"oneof=space attached both"`
 
 	// The name of the argument. If Named==true, for a verbose flag, "verbose" makes "--verbose" or
 	// "-verbose". If Named==false, it's the name of the positional argument, e.q.:
@@ -43,7 +68,7 @@ type Argument struct {
 	// If a short flag version is allowed, its name. (for a verbose flag, "v" makes -v).
 	ShortName string `yaml:"short-name"`
 
-	// Short description of the argument validate:"required".
+	// Short description of the argument.
 	ShortDescription string `yaml:"short-description"`
 
 	// The completion to suggest.
@@ -71,7 +96,12 @@ type Argument struct {
 	Example string `yaml:"example"`
 }
 
+// Command is a command of the tool, or a subcommand of another command.
 type Command struct {
+	// Name of the command of the definitions this command is based on. The other keys override those
+	// of the definition.
+	Use string `yaml:"use,omitempty" json:"-"`
+
 	///// Completion /////
 
 	// Command name.
@@ -108,9 +138,23 @@ type Command struct {
 	Example string `yaml:"example"`
 }
 
+// Definitions are arguments, groups of arguments and commands that others use as their base. Uses
+// are resolved when the description is read, so the definitions are only kept for reference.
+type Definitions struct {
+	// Arguments, by name.
+	Arguments map[string]Argument `yaml:"arguments"`
+
+	// Lists of arguments, by name, inserted together.
+	Groups map[string][]Argument `yaml:"groups"`
+
+	// Commands, by name.
+	Commands map[string]Command `yaml:"commands"`
+}
+
+// Completion tells how the value of an argument is completed.
 type Completion struct {
 	// One of "function", "static", "none", "file", "folder"
-	// Function: uses the return of Fish, Bash and Zsh as completion
+	// Function: uses the return of the code of each shell, or of Provider, as completion
 	// Static: uses the values in Values
 	// File: complete with a file name
 	// Folder: complete with a folder name
@@ -126,8 +170,56 @@ type Completion struct {
 	// Zsh code to return completions.
 	Zsh string `yaml:"zsh"`
 
+	// PowerShell code to output completions.
+	PowerShell string `yaml:"powershell" json:",omitempty"`
+
+	// Nushell code to return a list of completions.
+	Nushell string `yaml:"nushell" json:",omitempty"`
+
+	// Elvish code to output completions.
+	Elvish string `yaml:"elvish" json:",omitempty"`
+
+	// tcsh command whose output, split in words, are the completions. Its lines are joined with ;.
+	Tcsh string `yaml:"tcsh" json:",omitempty"`
+
+	// Emacs Lisp forms that return the list of completions. Without them, the Bash code is run in the
+	// shell and its lines are the completions.
+	Elisp string `yaml:"elisp" json:",omitempty"`
+
 	// Static list of values to suggest.
 	Values []string `yaml:"values"`
+
+	// Name of the provider whose functions return completions, instead of the code of each shell.
+	Provider string `yaml:"provider"`
+}
+
+// Provider is a completion function shared by arguments. Each generator writes it once, as a shell
+// function that prints the completions, one per line.
+type Provider struct {
+	// Body of the Fish function.
+	Fish string `yaml:"fish"`
+
+	// Body of the Bash function.
+	Bash string `yaml:"bash"`
+
+	// Body of the Zsh function.
+	Zsh string `yaml:"zsh"`
+
+	// Body of the PowerShell function.
+	PowerShell string `yaml:"powershell" json:",omitempty"`
+
+	// Body of the Nushell command, which returns a list of completions.
+	Nushell string `yaml:"nushell" json:",omitempty"`
+
+	// Body of the Elvish function.
+	Elvish string `yaml:"elvish" json:",omitempty"`
+
+	// Command of the tcsh alias, whose lines are joined with ;.
+	Tcsh string `yaml:"tcsh" json:",omitempty"`
+
+	// Body of the Emacs Lisp function, which returns a list of completions. Without it, the Bash body is
+	// run in the shell.
+	Elisp string `yaml:"elisp" json:",omitempty"`
 }
 
 // Provides default arguments
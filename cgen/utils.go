@@ -17,7 +17,8 @@ func newIndentedWriter(w io.Writer, indent string) *indentedWriter {
 }
 
 func (iw *indentedWriter) WriteLine(s string) error {
-	if iw.newline {
+	// Blank lines are not indented.
+	if iw.newline && s != "\n" {
 		if _, err := io.WriteString(iw.w, strings.Repeat(iw.indent, iw.level)); err != nil {
 			return err
 		}
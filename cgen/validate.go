@@ -0,0 +1,131 @@
+package cgen
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	completionTypes      = []string{"function", "static", "none", "file", "folder"}
+	longValueSeparators  = []string{"space", "equal", "both"}
+	shortValueSeparators = []string{"space", "attached", "both"}
+)
+
+// ArgumentError describes an argument with an invalid value.
+type ArgumentError struct {
+	// The tool and the commands leading to the argument, e.g. ["git", "remote", "add"].
+	Command []string
+
+	// Name of the argument, or its short name if it has no long one.
+	Argument string
+
+	// Location of the value in the description file, e.g. commands[0].arguments[1].completion.type.
+	Path string
+
+	// The invalid field, e.g. long-value-separator.
+	Field string
+
+	// The invalid value.
+	Value string
+
+	// Values accepted by the field.
+	Accepted []string
+}
+
+func (e ArgumentError) Error() string {
+	return fmt.Sprintf("%s: argument %q: %s", strings.Join(e.Command, " "), e.Argument, invalidValueMessage(e.Field, e.Value, e.Accepted))
+}
+
+// ValidationError lists every problem found in a CLI description.
+type ValidationError struct {
+	Errors []ArgumentError
+}
+
+// Diagnostics returns the errors as diagnostics, suggesting the closest accepted value.
+func (e *ValidationError) Diagnostics() []Diagnostic {
+	ds := make([]Diagnostic, len(e.Errors))
+	for i, err := range e.Errors {
+		ds[i] = Diagnostic{
+			Severity:   SeverityError,
+			Rule:       "invalid-value",
+			Path:       err.Path,
+			Message:    invalidValueMessage(err.Field, err.Value, err.Accepted),
+			Suggestion: suggest(err.Value, err.Accepted),
+		}
+	}
+	return ds
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the values that the generators rely on. It returns a *ValidationError listing
+// every offending argument, or nil if the description is valid.
+func Validate(cli *CLI) error {
+	var errs []ArgumentError
+	providers := providerNames(cli)
+	validateArguments(&errs, []string{cli.Name}, "", cli.Arguments, providers)
+	validateCommands(&errs, []string{cli.Name}, "", cli.Commands, providers)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func validateCommands(errs *[]ArgumentError, parents []string, path string, cmds []Command, providers []string) {
+	for i, cmd := range cmds {
+		cmdPath := fmt.Sprintf("%scommands[%d]", path, i)
+		names := slices.Concat(parents, []string{cmd.Name})
+		validateArguments(errs, names, cmdPath+".", cmd.Arguments, providers)
+		validateCommands(errs, names, cmdPath+".", cmd.Subcommands, providers)
+	}
+}
+
+func validateArguments(errs *[]ArgumentError, parents []string, path string, args []Argument, providers []string) {
+	for i, arg := range args {
+		check := func(field, value string, accepted []string) {
+			if slices.Contains(accepted, value) {
+				return
+			}
+			*errs = append(*errs, ArgumentError{
+				Command:  parents,
+				Argument: argumentName(arg),
+				Path:     fmt.Sprintf("%sarguments[%d].%s", path, i, field),
+				Field:    field,
+				Value:    value,
+				Accepted: accepted,
+			})
+		}
+		check("completion.type", arg.Completion.Type, completionTypes)
+		if arg.Completion.Provider != "" {
+			check("completion.provider", arg.Completion.Provider, providers)
+			check("completion.type", arg.Completion.Type, []string{"function"})
+		}
+		if arg.Named {
+			check("long-value-separator", arg.LongValueSeparator, longValueSeparators)
+			check("short-value-separator", arg.ShortValueSeparator, shortValueSeparators)
+		}
+	}
+}
+
+func argumentName(arg Argument) string {
+	if arg.Name == "" {
+		return arg.ShortName
+	}
+	return arg.Name
+}
+
+func invalidValueMessage(field, value string, accepted []string) string {
+	return fmt.Sprintf("the value %q is not valid for %s. Accepted values are %s", value, field, strings.Join(accepted, ", "))
+}
+
+// errInvalidValue is returned by the generators when they find a value that Validate rejects.
+func errInvalidValue(arg Argument, field, value string, accepted []string) error {
+	return fmt.Errorf("argument %q: %s", argumentName(arg), invalidValueMessage(field, value, accepted))
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+)
+
+// projectConfigFile is the name of the project configuration, looked up in the directory of the
+// description file.
+const projectConfigFile = ".cgen.yml"
+
+func init() {
+	RootCmd.PersistentFlags().StringArray("overlay", nil, "Overlay file to apply on top of the configuration file. Can be repeated, and is applied after the overlays of "+projectConfigFile+".")
+}
+
+// projectConfig holds the settings of a project, read from projectConfigFile.
+type projectConfig struct {
+	// Overlay files applied to the description, relative to the project configuration.
+	Overlays []string `yaml:"overlays"`
+}
+
+// loadProjectConfig reads the project configuration in dir. It returns its path, or "" if there is
+// none, and the configuration with its paths made relative to the working directory.
+func loadProjectConfig(dir string) (string, *projectConfig, error) {
+	path := filepath.Join(dir, projectConfigFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", &projectConfig{}, nil
+	} else if err != nil {
+		return "", nil, fmt.Errorf("could not read project configuration: %w", err)
+	}
+
+	var config projectConfig
+	if err := yaml.UnmarshalWithOptions(data, &config, yaml.Strict()); err != nil {
+		return "", nil, fmt.Errorf("could not read project configuration %s: %w", path, err)
+	}
+	for i, overlay := range config.Overlays {
+		if !filepath.IsAbs(overlay) {
+			config.Overlays[i] = filepath.Join(dir, overlay)
+		}
+	}
+	return path, &config, nil
+}
+
+// specOverlays returns the overlays of the project configuration in dir followed by the ones given
+// with --overlay, and the path of the project configuration, if there is one.
+func specOverlays(cmd *cobra.Command, dir string) ([]string, string, error) {
+	path, config, err := loadProjectConfig(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	overlays, err := cmd.Flags().GetStringArray("overlay")
+	if err != nil {
+		return nil, "", err
+	}
+	return append(config.Overlays, overlays...), path, nil
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+var diagnosticFormats = []string{"text", "json", "sarif"}
+
+func init() {
+	RootCmd.PersistentFlags().String("diagnostics-format", "text", "Format of the reported problems: "+strings.Join(diagnosticFormats, ", ")+".")
+}
+
+func checkDiagnosticsFormat(cmd *cobra.Command) error {
+	format, err := cmd.Flags().GetString("diagnostics-format")
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(diagnosticFormats, format) {
+		return fmt.Errorf("invalid diagnostics format %q. Accepted values are %s", format, strings.Join(diagnosticFormats, ", "))
+	}
+	return nil
+}
+
+// diagnosticsOutput returns where lint writes the diagnostics: the standard error when they are
+// text, like the problems reported by the other commands, and the standard output when they are
+// JSON or SARIF, so that they can be redirected to a file.
+func diagnosticsOutput(cmd *cobra.Command) io.Writer {
+	if format, _ := cmd.Flags().GetString("diagnostics-format"); format == "json" || format == "sarif" {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// writeDiagnostics writes the diagnostics in the format chosen by --diagnostics-format.
+func writeDiagnostics(cmd *cobra.Command, w io.Writer, ds []cgen.Diagnostic) {
+	format, _ := cmd.Flags().GetString("diagnostics-format")
+	if ds == nil {
+		ds = []cgen.Diagnostic{}
+	}
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(ds)
+	case "sarif":
+		cgen.WriteSARIF(w, ds)
+	default:
+		printDiagnostics(w, ds)
+	}
+}
+
+// printDiagnostics writes the diagnostics in a human readable format, with the source lines they
+// refer to.
+func printDiagnostics(w io.Writer, ds []cgen.Diagnostic) {
+	for _, d := range ds {
+		fmt.Fprintln(w, d)
+		if d.Snippet != "" {
+			fmt.Fprintln(w, d.Snippet)
+		}
+		if d.Suggestion != "" {
+			indent := ""
+			if d.Snippet != "" {
+				indent = strings.Repeat(" ", 6)
+			}
+			fmt.Fprintf(w, "%s= did you mean %q?\n", indent, d.Suggestion)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt PATH...",
+	Short: "Rewrites configuration files in the canonical format",
+	Long: `Rewrites YAML configuration files in the canonical format: keys in a fixed order, keys equal
+		to their default removed, two spaces of indentation and strings quoted only when needed.
+		Comments are kept.
+
+		The files are rewritten in place. If PATH is -, the configuration is read from the standard
+		input and written to the standard output. With --check, nothing is written: a diff of each file
+		that is not formatted is printed and the exit status is non-zero if there is any.
+
+		Usage:
+			- cgen fmt cli.yml
+			- cgen fmt --check cli.yml
+	`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		check, _ := cmd.Flags().GetBool("check")
+
+		unformatted := 0
+		for _, path := range args {
+			spec, err := loadSpec(cmd, path)
+			if err != nil {
+				exitOnSpecError(cmd, os.Stderr, err)
+			}
+
+			formatted, err := spec.Canonical()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not format %s: %s\n", path, err)
+				os.Exit(1)
+			}
+
+			if path == "-" && !check {
+				os.Stdout.Write(formatted)
+				continue
+			}
+
+			current := spec.Bytes()
+			if bytes.Equal(current, formatted) {
+				continue
+			}
+			if check {
+				fmt.Print(unifiedDiff(path, path, string(current), string(formatted)))
+				unformatted++
+				continue
+			}
+			if err := os.WriteFile(path, formatted, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write %s: %s\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		if unformatted > 0 {
+			fmt.Fprintf(os.Stderr, "%d files are not formatted\n", unformatted)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	fmtCmd.Flags().Bool("check", false, "Checks that the files are formatted, printing a diff of the ones that are not, without writing anything.")
+	RootCmd.AddCommand(fmtCmd)
+}
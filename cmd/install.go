@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+var installCmd = &cobra.Command{
+	Use:   "install PATH",
+	Short: "Installs the generated files where the shells look for them",
+	Long: `Generates the selected targets and installs them where each shell looks for completions.
+
+		By default the files are installed for the current user, in the XDG directories:
+			bash:       $XDG_DATA_HOME/bash-completion/completions
+			fish:       $XDG_CONFIG_HOME/fish/completions
+			zsh:        $XDG_DATA_HOME/zsh/site-functions (must be added to fpath)
+			powershell: $XDG_DATA_HOME/powershell/completions (must be loaded by the profile)
+			nushell:    $XDG_DATA_HOME/nushell/vendor/autoload
+			elvish:     $XDG_DATA_HOME/elvish/lib (must be used by rc.elv)
+			tcsh:       $XDG_DATA_HOME/tcsh/completions (must be sourced by .tcshrc)
+			emacs:      $XDG_DATA_HOME/emacs/site-lisp (must be loaded by the init file)
+			man:        $XDG_DATA_HOME/man/man1
+
+		With --system, they are installed under --prefix instead. The directories of bash and fish are
+		asked to pkg-config when it knows bash-completion and fish. --destdir is prepended to every
+		path, for staged installs when packaging.
+	`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cli, files := installedFiles(cmd, args[0])
+		for _, f := range files {
+			if err := cgen.WriteFile("", f); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not install %s: %s\n", f.Path, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Installed %s\n", f.Path)
+		}
+
+		// Unlike bash and fish, zsh has no per-user completion directory it looks into by default.
+		if !isSystemInstall(cmd) && installsTarget(cmd, "zsh") {
+			dir := filepath.Join(userDataDir(), "zsh", "site-functions")
+			fmt.Printf("Add %s to fpath in your .zshrc to enable the zsh completion of %s\n", dir, cli.Name)
+		}
+
+		// PowerShell, tcsh and Emacs look into no directory at all: completion scripts are loaded at
+		// startup.
+		destdir, _ := cmd.Flags().GetString("destdir")
+		for _, f := range files {
+			path := "/" + strings.TrimPrefix(strings.TrimPrefix(f.Path, destdir), "/")
+			switch filepath.Ext(f.Path) {
+			case ".ps1":
+				fmt.Printf("Add . '%s' to your PowerShell profile to enable the completion of %s\n", path, cli.Name)
+			case ".tcsh":
+				fmt.Printf("Add source %s to your .tcshrc to enable the tcsh completion of %s\n", path, cli.Name)
+			case ".el":
+				fmt.Printf("Add (load \"%s\") to your Emacs init file to enable the eshell completion of %s\n", path, cli.Name)
+			}
+		}
+
+		// Elvish finds the module, but only loads the ones that are used.
+		if installsTarget(cmd, "elvish") {
+			fmt.Printf("Add use %s to your rc.elv to enable the elvish completion of %s\n", cli.Name, cli.Name)
+		}
+	},
+}
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall PATH",
+	Short: "Removes the files installed by install",
+	Long: `Removes the files that install would have installed for the same configuration file and
+		options.
+	`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, files := installedFiles(cmd, args[0])
+		for _, f := range files {
+			if err := os.Remove(f.Path); errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not remove %s: %s\n", f.Path, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Removed %s\n", f.Path)
+		}
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{installCmd, uninstallCmd} {
+		addTargetFlags(cmd, false)
+		cmd.Flags().Bool("system", false, "Installs for all users, under --prefix, instead of for the current user.")
+		cmd.Flags().String("prefix", "/usr/local", "Installation prefix of system installs. Implies --system.")
+		cmd.Flags().String("destdir", "", "Directory prepended to every installed path. Implies --system.")
+		RootCmd.AddCommand(cmd)
+	}
+}
+
+func isSystemInstall(cmd *cobra.Command) bool {
+	system, _ := cmd.Flags().GetBool("system")
+	return system || cmd.Flags().Changed("prefix") || cmd.Flags().Changed("destdir")
+}
+
+func installsTarget(cmd *cobra.Command, name string) bool {
+	targets, _ := selectedTargets(cmd)
+	for _, t := range targets {
+		if t.generator.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// installedFiles loads the configuration file and returns the files of the selected targets, with
+// the absolute paths where they are installed.
+func installedFiles(cmd *cobra.Command, path string) (*cgen.CLI, []cgen.File) {
+	targets, err := selectedTargets(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not parse options: %s\n", err)
+		os.Exit(1)
+	}
+
+	spec, err := loadSpec(cmd, path)
+	if err != nil {
+		exitOnSpecError(cmd, os.Stderr, err)
+	}
+
+	if ds := validateSpec(cmd, spec); len(ds) > 0 {
+		writeDiagnostics(cmd, os.Stderr, ds)
+		os.Exit(1)
+	}
+
+	prefix, _ := cmd.Flags().GetString("prefix")
+	destdir, _ := cmd.Flags().GetString("destdir")
+	system := isSystemInstall(cmd)
+
+	files := []cgen.File{}
+	for _, t := range targets {
+		dir, name := installLocation(t.generator, spec.CLI, system, prefix)
+		for i, f := range t.files(spec.CLI) {
+			if i == 0 && name != "" {
+				f.Path = filepath.Join(dir, name)
+			} else if dir != "" {
+				f.Path = filepath.Join(dir, filepath.Base(f.Path))
+			} else if system {
+				f.Path = filepath.Join(prefix, "share", f.Path)
+			} else {
+				f.Path = filepath.Join(userDataDir(), f.Path)
+			}
+			if destdir != "" {
+				f.Path = filepath.Join(destdir, f.Path)
+			}
+			files = append(files, f)
+		}
+	}
+	return spec.CLI, files
+}
+
+// installLocation returns the directory where the shell of the generator looks for its files and,
+// if it differs from the generated one, the name of the main file. For unknown generators, it
+// returns an empty directory, meaning their default path is used under the data directory.
+func installLocation(g cgen.Generator, cli *cgen.CLI, system bool, prefix string) (string, string) {
+	data := userDataDir()
+	if system {
+		data = filepath.Join(prefix, "share")
+	}
+
+	switch g.Name() {
+	case "bash":
+		if system {
+			if dir := pkgConfigVariable("bash-completion", "completionsdir", prefix); dir != "" {
+				return dir, cli.Name
+			}
+		}
+		return filepath.Join(data, "bash-completion", "completions"), cli.Name
+	case "fish":
+		if system {
+			if dir := pkgConfigVariable("fish", "completionsdir", prefix); dir != "" {
+				return dir, ""
+			}
+			return filepath.Join(data, "fish", "vendor_completions.d"), ""
+		}
+		return filepath.Join(userConfigDir(), "fish", "completions"), ""
+	case "zsh":
+		return filepath.Join(data, "zsh", "site-functions"), ""
+	case "powershell":
+		return filepath.Join(data, "powershell", "completions"), ""
+	case "nushell":
+		return filepath.Join(data, "nushell", "vendor", "autoload"), ""
+	case "elvish":
+		return filepath.Join(data, "elvish", "lib"), ""
+	case "tcsh":
+		return filepath.Join(data, "tcsh", "completions"), ""
+	case "emacs":
+		return filepath.Join(data, "emacs", "site-lisp"), ""
+	case "man":
+		return filepath.Join(data, "man", "man1"), ""
+	}
+	return "", ""
+}
+
+// pkgConfigVariable asks pkg-config for a variable of a package installed under prefix. It returns
+// an empty string if pkg-config or the package is not available.
+func pkgConfigVariable(pkg, variable, prefix string) string {
+	out, err := exec.Command("pkg-config", "--define-variable=prefix="+prefix, "--variable="+variable, pkg).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func userDataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share")
+}
+
+func userConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config")
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint PATH",
+	Short: "Checks a configuration file for mistakes",
+	Long: `Checks a configuration file for mistakes that are not caught when parsing it, like duplicated
+		option names, aliases colliding with other commands, options shadowing global ones, static
+		completions without values and function completions missing the code for a shell.
+
+		The problems are written as text to the standard error, like the other commands do, or as
+		JSON or SARIF to the standard output, depending on --diagnostics-format. Exits with a non-zero
+		status if any error is found. Warnings are only reported.
+	`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec, err := loadSpec(cmd, args[0])
+		var diagnostics *cgen.DiagnosticError
+		if errors.As(err, &diagnostics) {
+			exitOnSpecError(cmd, diagnosticsOutput(cmd), err)
+		} else if err != nil {
+			exitOnSpecError(cmd, os.Stderr, err)
+		}
+
+		env, _ := cmd.Flags().GetBool("env")
+		ds := append(spec.Interpolate(env), spec.Lint()...)
+		writeDiagnostics(cmd, diagnosticsOutput(cmd), ds)
+		if cgen.HasErrors(ds) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lintCmd)
+}
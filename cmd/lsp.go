@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/acristoffers/cgen/lsp"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Runs a language server for configuration files",
+	Long: `Runs a language server for configuration files, speaking the Language Server Protocol over the
+		standard input and output.
+
+		It reports the problems found by lint while typing, documents each field on hover, completes
+		keys, accepted values and command paths, goes to the definition named by a use, to the files
+		matched by an include and to the anchor of YAML aliases, and offers code actions previewing
+		the lines of each shell generated for the argument under the cursor.
+	`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Language server stopped: %s\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lspCmd)
+}
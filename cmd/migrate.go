@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate PATH...",
+	Short: "Upgrades configuration files to the current version of the format",
+	Long: `Rewrites YAML configuration files written for an older version of the format, given by their
+		spec-version, in the current one. Files without a spec-version are of version 1. The files are
+		written in the canonical format, as fmt does, and comments are kept.
+
+		Each transformation is listed in the standard error. Included files and overlays are not
+		rewritten: the changes they need are listed to be made by hand.
+
+		The files are rewritten in place. If PATH is -, the configuration is read from the standard
+		input and written to the standard output. With --check, nothing is written: a diff of each file
+		that is not at the current version is printed and the exit status is non-zero if there is any.
+
+		Usage:
+			- cgen migrate cli.yml
+			- cgen migrate --check cli.yml
+	`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		check, _ := cmd.Flags().GetBool("check")
+
+		outdated := 0
+		for _, path := range args {
+			spec, err := loadSpec(cmd, path)
+			if err != nil {
+				exitOnSpecError(cmd, os.Stderr, err)
+			}
+
+			migrated, changes, err := spec.Migrate()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not migrate %s: %s\n", path, err)
+				os.Exit(1)
+			}
+
+			if len(changes) == 0 {
+				fmt.Fprintf(os.Stderr, "%s is already at version %d\n", path, cgen.SpecVersion)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: version %d to %d\n", path, max(spec.CLI.SpecVersion, 1), cgen.SpecVersion)
+				for _, c := range changes {
+					fmt.Fprintf(os.Stderr, "  %s\n", c)
+				}
+			}
+
+			if path == "-" && !check {
+				os.Stdout.Write(migrated)
+				continue
+			}
+
+			current := spec.Bytes()
+			if bytes.Equal(current, migrated) {
+				continue
+			}
+			if check {
+				fmt.Print(unifiedDiff(path, path, string(current), string(migrated)))
+				outdated++
+				continue
+			}
+			if err := os.WriteFile(path, migrated, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write %s: %s\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		if outdated > 0 {
+			fmt.Fprintf(os.Stderr, "%d files are not at version %d\n", outdated, cgen.SpecVersion)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	migrateCmd.Flags().Bool("check", false, "Checks that the files are at the current version, printing a diff of the ones that are not, without writing anything.")
+	RootCmd.AddCommand(migrateCmd)
+}
@@ -5,11 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/acristoffers/cgen/cgen"
-	"github.com/go-playground/validator/v10"
 	"github.com/goccy/go-yaml"
 	"github.com/spf13/cobra"
 )
@@ -17,17 +14,37 @@ import (
 var RootCmd = &cobra.Command{
 	Use:   "cgen [PATH]",
 	Short: "Generates CLI completions from a configuration file",
-	Long: `Generates Fish, BASH and ZSH completions for a tool from a yaml description file.
+	Long: `Generates Fish, BASH, ZSH, PowerShell, Nushell, Elvish, tcsh and Emacs completions for a tool from a YAML, JSON or TOML description file.
 
-		This tool creates completion configuration files for Fish, BASH and ZSH based on a configuration
-		file, allowing you to create completion files for existing tools.
+		This tool creates completion configuration files for Fish, BASH, ZSH, PowerShell, Nushell,
+		Elvish, tcsh and Emacs based on a configuration file, allowing you to create completion files
+		for existing tools.
 
 		Usage:
 			To generate the completion:
 			- cgen config.yaml
+			To generate only some targets, in another directory:
+			- cgen --target bash,man --output build/share config.yaml
+			To install the fish completion as a vendor completion:
+			- cgen --path fish=fish/vendor_completions.d/cli.fish config.yaml
+			To print a single target:
+			- cgen --target zsh --stdout config.yaml
+			To read a JSON configuration from the standard input:
+			- cgen --format json - < config.json
+			To generate the completion of a variant of the tool:
+			- cgen --overlay windows.yml config.yaml
+			To check that the generated files are up to date:
+			- cgen --check config.yaml
 			To generate an example configuration:
 			- cgen --sample
 	`,
+	Args: cobra.ArbitraryArgs,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkSpecFormat(cmd); err != nil {
+			return err
+		}
+		return checkDiagnosticsFormat(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if version, err := cmd.Flags().GetBool("version"); err == nil && version {
 			fmt.Printf("cgen version %s", cgen.Version)
@@ -41,7 +58,18 @@ var RootCmd = &cobra.Command{
 			var buf bytes.Buffer
 			enc := yaml.NewEncoder(&buf)
 			enc.Encode(generateSample())
-			os.Stdout.Write(buf.Bytes())
+
+			// The encoder writes every field, so format the sample to leave out the defaults.
+			spec, err := cgen.ParseSpec("sample.yml", buf.Bytes())
+			if err != nil {
+				exitOnSpecError(cmd, os.Stderr, err)
+			}
+			formatted, err := spec.Canonical()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not format sample: %s\n", err)
+				os.Exit(1)
+			}
+			os.Stdout.Write(formatted)
 			return
 		} else if err != nil {
 			fmt.Fprintf(os.Stderr, "Could not parse options: %s\n", err)
@@ -53,48 +81,67 @@ var RootCmd = &cobra.Command{
 			os.Exit(0)
 		}
 
-		filePath, err := filepath.Abs(args[0])
+		targets, err := selectedTargets(cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not get configuration path: %s\n", err)
-			os.Exit(1)
-		}
-
-		if _, err := os.Stat(filePath); err != nil {
-			fmt.Fprintf(os.Stderr, "Could not open configuration file: %s\n", err)
+			fmt.Fprintf(os.Stderr, "Could not parse options: %s\n", err)
 			os.Exit(1)
 		}
 
-		binary, err := os.ReadFile(filePath)
+		spec, err := loadSpec(cmd, args[0])
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not read configuration file: %s\n", err)
-			os.Exit(1)
+			exitOnSpecError(cmd, os.Stderr, err)
 		}
 
-		var cli cgen.CLI
-		dec := yaml.NewDecoder(strings.NewReader(string(binary)), yaml.Validator(validator.New()), yaml.Strict())
-		if err := dec.Decode(&cli); err != nil {
-			fmt.Fprintf(os.Stderr, "Could not parse configuration file: %s\n", err)
+		if ds := validateSpec(cmd, spec); len(ds) > 0 {
+			writeDiagnostics(cmd, os.Stderr, ds)
 			os.Exit(1)
 		}
-
-		if err := cgen.GenerateBashCompletions(&cli); err != nil {
-			log.Fatal("Error generating BASH completion: ", err.Error())
+		cli := spec.CLI
+
+		if stdout, err := cmd.Flags().GetBool("stdout"); err == nil && stdout {
+			if len(targets) != 1 {
+				fmt.Fprintf(os.Stderr, "Exactly one target must be selected with --target when using --stdout\n")
+				os.Exit(1)
+			}
+			if err := targets[0].generator.Write(cli, os.Stdout); err != nil {
+				log.Fatalf("Error generating %s output: %s", targets[0].generator.Name(), err.Error())
+				os.Exit(1)
+			}
+			return
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not parse options: %s\n", err)
 			os.Exit(1)
 		}
 
-		if err := cgen.GenerateFishCompletions(&cli); err != nil {
-			log.Fatal("Error generating Fish completion: ", err.Error())
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not parse options: %s\n", err)
 			os.Exit(1)
 		}
 
-		if err := cgen.GenerateZshCompletions(&cli); err != nil {
-			log.Fatal("Error generating ZSH completion: ", err.Error())
+		if check, err := cmd.Flags().GetBool("check"); err == nil && check {
+			stale, err := checkTargets(cli, targets, output, os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not check generated files: %s\n", err)
+				os.Exit(1)
+			}
+			if stale > 0 {
+				fmt.Fprintf(os.Stderr, "%d generated files are out of date\n", stale)
+				os.Exit(1)
+			}
+			return
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not parse options: %s\n", err)
 			os.Exit(1)
 		}
 
-		if err := cgen.GenerateManPage(&cli); err != nil {
-			log.Fatal("Error generating man pages: ", err.Error())
-			os.Exit(1)
+		for _, t := range targets {
+			for _, f := range t.files(cli) {
+				if err := cgen.WriteFile(output, f); err != nil {
+					log.Fatalf("Error generating %s output: %s", t.generator.Name(), err.Error())
+					os.Exit(1)
+				}
+			}
 		}
 	},
 }
@@ -102,10 +149,13 @@ var RootCmd = &cobra.Command{
 func init() {
 	RootCmd.Flags().BoolP("version", "v", false, "Prints the version.")
 	RootCmd.Flags().BoolP("sample", "s", false, "Prints a sample configuration.")
+	RootCmd.Flags().Bool("check", false, "Checks that the generated files on disk are up to date, printing a diff of the stale ones, without writing anything.")
+	RootCmd.Flags().Bool("stdout", false, "Writes the selected target to the standard output instead of a file. Only the main page of the man target is written.")
 }
 
 func generateSample() cgen.CLI {
 	cli := cgen.CLI{}
+	cli.SpecVersion = cgen.SpecVersion
 	cli.Name = "cli"
 	cli.Version = "0.0.1"
 	cli.LongDescription = "cli long desc"
@@ -171,6 +221,11 @@ func generateSample() cgen.CLI {
 	opt2.Completion.Bash = "printf 'a\nb\nc'"
 	opt2.Completion.Fish = "printf 'a\nb\nc'"
 	opt2.Completion.Zsh = "printf 'a\nb\nc'"
+	opt2.Completion.PowerShell = "'a', 'b', 'c'"
+	opt2.Completion.Nushell = `["a" "b" "c"]`
+	opt2.Completion.Elvish = "put a b c"
+	opt2.Completion.Tcsh = "echo a b c"
+	opt2.Completion.Elisp = `'("a" "b" "c")`
 	opt2.LongValueSeparator = "space"
 	opt2.ShortValueSeparator = "space"
 	opt2.Deprecated = "replaced by nothing"
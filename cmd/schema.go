@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the JSON Schema of the configuration file",
+	Long: `Prints a JSON Schema of the configuration file, with the documentation of each field, its
+		accepted values and its default.
+
+		Editors using yaml-language-server validate and complete configuration files that start with:
+			# yaml-language-server: $schema=path/to/cgen.schema.json
+
+		Usage:
+			- cgen schema > cgen.schema.json
+	`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		schema, err := cgen.JSONSchema()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not generate schema: %s\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(schema)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(schemaCmd)
+}
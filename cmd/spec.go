@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	RootCmd.PersistentFlags().Bool("env", false, "Interpolate environment variables, referenced as ${env.NAME}, in the texts of the configuration file.")
+	RootCmd.PersistentFlags().String("format", "", "Format of the configuration file: "+strings.Join(cgen.SpecFormats, ", ")+". Defaults to the one given by its extension, or to yaml.")
+}
+
+func checkSpecFormat(cmd *cobra.Command) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if format != "" && !slices.Contains(cgen.SpecFormats, format) {
+		return fmt.Errorf("invalid format %q. Accepted values are %s", format, strings.Join(cgen.SpecFormats, ", "))
+	}
+	return nil
+}
+
+// loadSpec reads and decodes the configuration file at path, or the standard input if path is "-".
+// Problems in the file are returned as a *cgen.DiagnosticError.
+func loadSpec(cmd *cobra.Command, path string) (*cgen.Spec, error) {
+	format, _ := cmd.Flags().GetString("format")
+
+	if path == "-" {
+		binary, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("could not read configuration from standard input: %w", err)
+		}
+		if format == "" {
+			format = "yaml"
+		}
+		return parseSpec(cmd, "<stdin>", ".", binary, format)
+	}
+
+	filePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not get configuration path: %w", err)
+	}
+
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("could not open configuration file: %w", err)
+	}
+
+	binary, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration file: %w", err)
+	}
+
+	if format == "" {
+		format = cgen.SpecFormat(path)
+	}
+	return parseSpec(cmd, path, filepath.Dir(filePath), binary, format)
+}
+
+// parseSpec decodes the configuration file with the overlays of the project configuration in dir and
+// of --overlay applied. The project configuration is listed in the files of the description, so that
+// watch reloads it.
+func parseSpec(cmd *cobra.Command, path, dir string, binary []byte, format string) (*cgen.Spec, error) {
+	overlays, config, err := specOverlays(cmd, dir)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := cgen.ParseSpecFormat(path, binary, format, overlays...)
+	if err == nil && config != "" {
+		spec.Files = append(spec.Files, config)
+	}
+	return spec, err
+}
+
+// validateSpec interpolates the variables of the description, with the environment variables if --env
+// is given, and returns the problems that prevent generating it.
+func validateSpec(cmd *cobra.Command, spec *cgen.Spec) []cgen.Diagnostic {
+	env, _ := cmd.Flags().GetBool("env")
+	return append(spec.Interpolate(env), spec.Validate()...)
+}
+
+// exitOnSpecError prints err, as printSpecError does, and exits.
+func exitOnSpecError(cmd *cobra.Command, w io.Writer, err error) {
+	printSpecError(cmd, w, err)
+	os.Exit(1)
+}
+
+// printSpecError prints err, which may list the problems of a configuration file, to w. The problems
+// are written in the format chosen by --diagnostics-format.
+func printSpecError(cmd *cobra.Command, w io.Writer, err error) {
+	var diagnostics *cgen.DiagnosticError
+	if errors.As(err, &diagnostics) {
+		writeDiagnostics(cmd, w, diagnostics.Diagnostics)
+	} else {
+		fmt.Fprintf(w, "%s\n", err)
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+// A target is a generator selected from the command line, possibly with its output path overridden.
+type target struct {
+	generator cgen.Generator
+
+	// Overrides the default path of the generator's main file, if not empty.
+	path string
+}
+
+func init() {
+	addTargetFlags(RootCmd, true)
+}
+
+// addTargetFlags adds the --target flag to cmd and, if output is set, the flags that choose where
+// the targets are written.
+func addTargetFlags(cmd *cobra.Command, output bool) {
+	cmd.Flags().StringSliceP("target", "t", nil, "Targets to generate (default all). Available: "+strings.Join(generatorNames(), ", ")+".")
+	if output {
+		cmd.Flags().StringP("output", "o", "share", "Root directory of the generated files.")
+		cmd.Flags().StringArray("path", nil, "Overrides the path of a target, relative to the output root, as TARGET=PATH (e.g. fish=fish/vendor_completions.d/cli.fish).")
+	}
+}
+
+func generatorNames() []string {
+	names := []string{}
+	for _, g := range cgen.Generators() {
+		names = append(names, g.Name())
+	}
+	return names
+}
+
+// selectedTargets returns the targets chosen by the --target and --path flags.
+func selectedTargets(cmd *cobra.Command) ([]target, error) {
+	names, err := cmd.Flags().GetStringSlice("target")
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		names = generatorNames()
+	}
+
+	paths := []string{}
+	if cmd.Flags().Lookup("path") != nil {
+		if paths, err = cmd.Flags().GetStringArray("path"); err != nil {
+			return nil, err
+		}
+	}
+	overrides := map[string]string{}
+	for _, p := range paths {
+		name, path, ok := strings.Cut(p, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid path override %q, expected TARGET=PATH", p)
+		}
+		if _, ok := cgen.LookupGenerator(name); !ok {
+			return nil, fmt.Errorf("unknown target %q in path override. Available: %s", name, strings.Join(generatorNames(), ", "))
+		}
+		overrides[name] = path
+	}
+
+	targets := []target{}
+	for _, name := range names {
+		g, ok := cgen.LookupGenerator(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown target %q. Available: %s", name, strings.Join(generatorNames(), ", "))
+		}
+		targets = append(targets, target{generator: g, path: overrides[name]})
+	}
+	return targets, nil
+}
+
+// checkTargets generates the files of the targets in memory and compares them with the ones under
+// the output root, writing a diff of each stale file to w. It returns the number of stale files.
+func checkTargets(cli *cgen.CLI, targets []target, root string, w io.Writer) (int, error) {
+	stale := 0
+	for _, t := range targets {
+		for _, f := range t.files(cli) {
+			var generated bytes.Buffer
+			if err := f.Write(&generated); err != nil {
+				return stale, fmt.Errorf("could not generate %s output: %w", t.generator.Name(), err)
+			}
+
+			path := outputPath(root, f.Path)
+			current, err := os.ReadFile(path)
+			name := path
+			if errors.Is(err, fs.ErrNotExist) {
+				name = "/dev/null"
+			} else if err != nil {
+				return stale, err
+			}
+
+			if diff := unifiedDiff(name, path, string(current), generated.String()); diff != "" {
+				fmt.Fprint(w, diff)
+				stale++
+			}
+		}
+	}
+	return stale, nil
+}
+
+// outputPath returns where a file is written, as cgen.WriteFile does.
+func outputPath(root, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// files returns the files produced by the target. If its path is overridden, the main file is moved
+// there and the others are placed in the same directory.
+func (t target) files(cli *cgen.CLI) []cgen.File {
+	files := cgen.GeneratorFiles(t.generator, cli)
+	if t.path == "" {
+		return files
+	}
+	for i := range files {
+		if i == 0 {
+			files[i].Path = t.path
+		} else {
+			files[i].Path = filepath.Join(filepath.Dir(t.path), filepath.Base(files[i].Path))
+		}
+	}
+	return files
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"al.essio.dev/pkg/shellescape"
+	"github.com/acristoffers/cgen/cgen"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch PATH",
+	Short: "Regenerates the completions whenever the configuration file changes",
+	Long: `Generates the selected targets, then regenerates them every time the configuration file, or a
+		file it includes, changes.
+
+		When the configuration file is invalid its problems are printed and the files generated last
+		are kept. With --reload SHELL, a command that loads the new completion into a running SHELL is
+		printed after each generation.
+
+		Usage:
+			- cgen watch --target fish --reload fish cli.yml
+	`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		targets, err := selectedTargets(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not parse options: %s\n", err)
+			os.Exit(1)
+		}
+
+		if args[0] == "-" {
+			fmt.Fprintf(os.Stderr, "Cannot watch the standard input\n")
+			os.Exit(1)
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		reload, _ := cmd.Flags().GetString("reload")
+		if reload != "" {
+			if _, ok := reloadSnippets[reload]; !ok {
+				fmt.Fprintf(os.Stderr, "Could not parse options: cannot reload the completion of %q\n", reload)
+				os.Exit(1)
+			}
+			if !slices.ContainsFunc(targets, func(t target) bool { return t.generator.Name() == reload }) {
+				fmt.Fprintf(os.Stderr, "Could not parse options: --reload %s requires the %s target\n", reload, reload)
+				os.Exit(1)
+			}
+		}
+
+		w := watcher{cmd: cmd, path: args[0], targets: targets, output: output, reload: reload}
+		w.regenerate()
+		for {
+			time.Sleep(interval)
+			if !w.changed() {
+				continue
+			}
+			// Editors may write a file in several steps, so wait until it stops changing.
+			for time.Sleep(interval); w.changed(); time.Sleep(interval) {
+			}
+			w.regenerate()
+		}
+	},
+}
+
+// reloadSnippets returns, for each target whose output can be loaded into a running shell, the
+// command that does it given the path of the generated file.
+var reloadSnippets = map[string]func(path string) string{
+	"bash": func(path string) string { return "source " + shellescape.Quote(path) },
+	"fish": func(path string) string { return "source " + shellescape.Quote(path) },
+	"zsh":  func(path string) string { return "source " + shellescape.Quote(path) },
+	"powershell": func(path string) string {
+		return ". '" + strings.ReplaceAll(path, "'", "''") + "'"
+	},
+	"nushell": func(path string) string {
+		return "use " + strconv.Quote(path) + " *"
+	},
+	"tcsh": func(path string) string { return "source " + shellescape.Quote(path) },
+	"elvish": func(path string) string {
+		return "eval (slurp < '" + strings.ReplaceAll(path, "'", "''") + "')"
+	},
+}
+
+func init() {
+	addTargetFlags(watchCmd, true)
+	watchCmd.Flags().Duration("interval", 500*time.Millisecond, "How often the files are checked for changes.")
+	watchCmd.Flags().String("reload", "", "Prints the command that reloads the completion in a running shell (bash, fish, zsh, powershell, nushell, elvish or tcsh) after each generation.")
+	RootCmd.AddCommand(watchCmd)
+}
+
+// watcher regenerates the targets of a configuration file and tracks the files it was read from.
+type watcher struct {
+	cmd     *cobra.Command
+	path    string
+	targets []target
+	output  string
+	reload  string
+
+	// Modification times and sizes of the watched files, as seen by the last call of changed.
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// regenerate generates the targets, printing the problems of the configuration file instead if
+// there are any. The files are only written if all of them could be generated.
+func (w *watcher) regenerate() {
+	spec, err := loadSpec(w.cmd, w.path)
+	if err == nil {
+		w.watch(spec.Files)
+	} else {
+		// The files included by an invalid description are unknown, so keep watching the last ones.
+		if w.stamps == nil {
+			w.watch(nil)
+		}
+		printSpecError(w.cmd, os.Stderr, err)
+		fmt.Fprintf(os.Stderr, "%s is invalid, keeping the previous output\n", w.path)
+		return
+	}
+
+	if ds := validateSpec(w.cmd, spec); len(ds) > 0 {
+		writeDiagnostics(w.cmd, os.Stderr, ds)
+		fmt.Fprintf(os.Stderr, "%s is invalid, keeping the previous output\n", w.path)
+		return
+	}
+	if ds := spec.Lint(); len(ds) > 0 {
+		writeDiagnostics(w.cmd, os.Stderr, ds)
+	}
+
+	files := []cgen.File{}
+	reload := ""
+	for _, t := range w.targets {
+		for i, f := range t.files(spec.CLI) {
+			var buf bytes.Buffer
+			if err := f.Write(&buf); err != nil {
+				fmt.Fprintf(os.Stderr, "Error generating %s output: %s\n", t.generator.Name(), err)
+				return
+			}
+			files = append(files, cgen.File{Path: f.Path, Write: func(w io.Writer) error {
+				_, err := w.Write(buf.Bytes())
+				return err
+			}})
+			if i == 0 && t.generator.Name() == w.reload {
+				reload = outputPath(w.output, f.Path)
+			}
+		}
+	}
+
+	for _, f := range files {
+		if err := cgen.WriteFile(w.output, f); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not write %s: %s\n", outputPath(w.output, f.Path), err)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "[%s] Generated %d files from %s\n", time.Now().Format(time.TimeOnly), len(files), w.path)
+
+	if reload != "" {
+		if abs, err := filepath.Abs(reload); err == nil {
+			reload = abs
+		}
+		fmt.Println(reloadSnippets[w.reload](reload))
+	}
+}
+
+// watch replaces the watched files by the configuration file and paths. The files are stamped now,
+// so that only later changes are noticed.
+func (w *watcher) watch(paths []string) {
+	w.stamps = map[string]fileStamp{}
+	for _, p := range append([]string{w.path}, paths...) {
+		if abs, err := filepath.Abs(p); err == nil {
+			w.stamps[abs] = stampOf(abs)
+		}
+	}
+}
+
+// changed reports whether any watched file changed since the last call.
+func (w *watcher) changed() bool {
+	changed := false
+	for p, old := range w.stamps {
+		if s := stampOf(p); s != old {
+			w.stamps[p] = s
+			changed = true
+		}
+	}
+	return changed
+}
+
+// stampOf returns the modification time and size of a file, or zero if it cannot be read, so that
+// deleting and recreating a file counts as a change.
+func stampOf(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{info.ModTime(), info.Size()}
+}
@@ -0,0 +1,247 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/acristoffers/cgen/cgen"
+)
+
+// previewTargets are the generators whose lines can be previewed by a code action.
+var previewTargets = []string{"fish", "bash", "zsh", "powershell", "nushell", "elvish", "tcsh", "emacs"}
+
+var (
+	// A line holding a key, possibly as the first key of a sequence item.
+	keyLine = regexp.MustCompile(`^(\s*)((?:- +)*)([\w-]+)\s*:(?:\s|$)`)
+
+	// The start of a line where a key is being typed.
+	partialKey = regexp.MustCompile(`^(\s*)((?:- +)*)([\w-]*)$`)
+
+	// The start of a line where the value of a key is being typed.
+	partialValue = regexp.MustCompile(`^(\s*)((?:- +)*)([\w-]+)\s*:\s*["']?([\w-]*)$`)
+
+	// The path of an argument, at the start of the path of one of its fields.
+	argumentPath = regexp.MustCompile(`^(?:.*\.)?arguments\[\d+\]`)
+)
+
+// toDiagnostic converts a diagnostic to the protocol. lines are the lines of the file it is in, if
+// known, to count the characters of its range in UTF-16 code units.
+func toDiagnostic(d cgen.Diagnostic, lines []string) diagnostic {
+	result := diagnostic{
+		Severity: severityError,
+		Code:     d.Rule,
+		Source:   "cgen",
+		Message:  d.Message,
+	}
+	if d.Severity == cgen.SeverityWarning {
+		result.Severity = severityWarning
+	}
+	if d.Suggestion != "" {
+		result.Message += fmt.Sprintf(". Did you mean %q?", d.Suggestion)
+	}
+	if d.Position != nil {
+		result.Range = lineRange(lines, d.Position.Line-1, d.Position.Column-1, d.Position.Length)
+	}
+	return result
+}
+
+// lineRange returns the range of length runes from column on a line of lines. Without lines, runes are
+// counted as single UTF-16 code units, which they are outside of the supplementary planes.
+func lineRange(lines []string, line, column, length int) textRange {
+	start, end := position{line, column}, position{line, column + length}
+	if line >= 0 && line < len(lines) {
+		start.Character = utf16Column(lines[line], column)
+		end.Character = utf16Column(lines[line], column+length)
+	}
+	return textRange{start, end}
+}
+
+// runePosition converts a position of the protocol to one whose character counts the runes of its
+// line, like the columns of the decoder.
+func (d *document) runePosition(p position) position {
+	if p.Line < len(d.lines) {
+		p.Character = runeColumn(d.lines[p.Line], p.Character)
+	}
+	return p
+}
+
+// keyPath returns the keys of the mappings that hold the line, from the outermost, found by looking
+// for less indented keys above it. column is where the keys of the innermost mapping start.
+func (d *document) keyPath(line, column int) []string {
+	path := []string{}
+	for i := min(line, len(d.lines)) - 1; i >= 0 && column > 0; i-- {
+		m := keyLine.FindStringSubmatch(d.lines[i])
+		if m == nil {
+			continue
+		}
+		if keyColumn := len(m[1]) + len(m[2]); keyColumn < column {
+			path = append([]string{m[3]}, path...)
+			column = keyColumn
+		}
+	}
+	return path
+}
+
+// keyAt returns the path of the key on the line, without indexes, or an empty string if the line has
+// no key.
+func (d *document) keyAt(line int) string {
+	if line >= len(d.lines) {
+		return ""
+	}
+	m := keyLine.FindStringSubmatch(d.lines[line])
+	if m == nil {
+		return ""
+	}
+	return strings.Join(append(d.keyPath(line, len(m[1])+len(m[2])), m[3]), ".")
+}
+
+func (d *document) hover(pos position) *hover {
+	path := d.keyAt(pos.Line)
+	field, ok := cgen.LookupField(path)
+	if !ok {
+		return nil
+	}
+	return &hover{Contents: markupContent{Kind: "markdown", Value: fieldDocumentation(field)}}
+}
+
+func fieldDocumentation(field cgen.Field) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** (%s)", field.Name, field.Type)
+	if field.Description != "" {
+		fmt.Fprintf(&b, "\n\n%s", field.Description)
+	}
+	if len(field.Values) > 0 {
+		fmt.Fprintf(&b, "\n\nAccepted values: `%s`", strings.Join(field.Values, "`, `"))
+	}
+	if field.Default != nil {
+		fmt.Fprintf(&b, "\n\nDefault: `%v`", field.Default)
+	}
+	return b.String()
+}
+
+func (d *document) definition(pos position) []location {
+	if d.spec == nil || !d.current {
+		return nil
+	}
+	locations := []location{}
+	for _, p := range d.spec.DefinitionAt(pos.Line+1, pos.Character+1) {
+		uri, lines := d.uri, d.lines
+		if p.File != d.spec.File {
+			uri, lines = fileURI(p.File), nil
+		}
+		locations = append(locations, location{uri, lineRange(lines, p.Line-1, p.Column-1, p.Length)})
+	}
+	return locations
+}
+
+// completion suggests the keys accepted where a key is being typed, the accepted values of a key,
+// and the names of subcommands in command lines starting with the name of the tool.
+func (d *document) completion(pos position) []completionItem {
+	items := []completionItem{}
+	if pos.Line >= len(d.lines) {
+		return items
+	}
+	line := []rune(d.lines[pos.Line])
+	before := string(line[:min(pos.Character, len(line))])
+
+	if m := partialKey.FindStringSubmatch(before); m != nil {
+		path := strings.Join(d.keyPath(pos.Line, len(m[1])+len(m[2])), ".")
+		for _, f := range cgen.FieldsAt(path) {
+			items = append(items, completionItem{
+				Label:         f.Name,
+				Kind:          completionKindProperty,
+				Detail:        f.Type,
+				Documentation: &markupContent{"markdown", fieldDocumentation(f)},
+				InsertText:    f.Name + ": ",
+			})
+		}
+		return items
+	}
+
+	if m := partialValue.FindStringSubmatch(before); m != nil {
+		path := strings.Join(append(d.keyPath(pos.Line, len(m[1])+len(m[2])), m[3]), ".")
+		field, _ := cgen.LookupField(path)
+		values := field.Values
+		if field.Type == "boolean" {
+			values = []string{"true", "false"}
+		}
+		for _, v := range values {
+			items = append(items, completionItem{Label: v, Kind: completionKindValue})
+		}
+		if len(items) > 0 {
+			return items
+		}
+	}
+
+	return append(items, d.commandCompletion(before)...)
+}
+
+// commandCompletion suggests the subcommands that can follow the command line at the end of text,
+// like "git remote " in an example, in a usage or in a long description.
+func (d *document) commandCompletion(text string) []completionItem {
+	items := []completionItem{}
+	if d.spec == nil {
+		return items
+	}
+	cli := d.spec.CLI
+
+	start := strings.LastIndex(text, cli.Name+" ")
+	if start < 0 || start > 0 && !strings.ContainsAny(text[start-1:start], " \t:'\"`|$(") {
+		return items
+	}
+	words := strings.Fields(text[start:])
+	if !strings.HasSuffix(text, " ") && len(words) > 0 {
+		words = words[:len(words)-1]
+	}
+
+	commands := cli.Commands
+	for _, word := range words[1:] {
+		i := slices.IndexFunc(commands, func(c cgen.Command) bool {
+			return c.Name == word || slices.Contains(c.Aliases, word)
+		})
+		if i < 0 {
+			return items
+		}
+		commands = commands[i].Subcommands
+	}
+	for _, c := range commands {
+		items = append(items, completionItem{Label: c.Name, Kind: completionKindModule, Detail: c.ShortDescription})
+	}
+	return items
+}
+
+// codeActions offers to preview the lines generated for the argument at the position.
+func (d *document) codeActions(pos position) []codeAction {
+	actions := []codeAction{}
+	if d.spec == nil || !d.current {
+		return actions
+	}
+	path := argumentPath.FindString(d.spec.PathAt(pos.Line+1, pos.Character+1))
+	if path == "" {
+		return actions
+	}
+	for _, target := range previewTargets {
+		title := fmt.Sprintf("Preview the %s completion of %s", target, path)
+		actions = append(actions, codeAction{
+			Title:   title,
+			Kind:    "source",
+			Command: &command{Title: title, Command: previewCommand, Arguments: []any{d.uri, path, target}},
+		})
+	}
+	return actions
+}
+
+// preview returns the lines that the generator of target writes for the argument at path.
+func (d *document) preview(path, target string) (string, error) {
+	g, ok := cgen.LookupGenerator(target)
+	if !ok {
+		return "", fmt.Errorf("unknown target %q", target)
+	}
+	lines, err := cgen.ArgumentLines(g, d.spec.CLI, path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s completion of %s:\n%s", target, path, strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,301 @@
+// Package lsp implements a language server for CLI description files, speaking the Language Server
+// Protocol over a pair of streams.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/acristoffers/cgen/cgen"
+)
+
+// previewCommand is the command run by the code actions that preview the generated lines.
+const previewCommand = "cgen.preview"
+
+type server struct {
+	w         io.Writer
+	documents map[string]*document
+	shutdown  bool
+
+	// URIs of the included files that have diagnostics published by each document.
+	included map[string][]string
+}
+
+// document is an open description file.
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	// The last version of the document that could be decoded, used to answer requests while the
+	// current one is being edited.
+	spec *cgen.Spec
+
+	// Whether spec was decoded from the current text, so that its positions are exact.
+	current bool
+}
+
+// Serve answers the requests read from r, writing the responses to w, until the client asks it to
+// exit or r is closed.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{w: w, documents: map[string]*document{}, included: map[string][]string{}}
+	reader := bufio.NewReader(r)
+	for {
+		msg, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if errors.Is(err, errParse) {
+			// The ID of the request cannot be known, so the error is answered with a null one.
+			id := json.RawMessage("null")
+			if err := writeMessage(s.w, &message{ID: &id, Error: &responseError{codeParseError, err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit requested before shutdown")
+			}
+			return nil
+		}
+
+		result, rerr := s.safeHandle(msg)
+		if msg.ID == nil {
+			continue
+		}
+		response := &message{ID: msg.ID, Error: rerr}
+		if rerr == nil {
+			data, err := json.Marshal(result)
+			if err != nil {
+				response.Error = &responseError{codeInternalError, err.Error()}
+			} else {
+				response.Result = (*json.RawMessage)(&data)
+			}
+		}
+		if err := writeMessage(s.w, response); err != nil {
+			return err
+		}
+	}
+}
+
+// safeHandle runs handle, answering with an internal error if it panics, so that a bug in a request
+// does not stop the server.
+func (s *server) safeHandle(msg *message) (result any, rerr *responseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, rerr = nil, &responseError{codeInternalError, fmt.Sprintf("%s failed: %v", msg.Method, r)}
+		}
+	}()
+	return s.handle(msg)
+}
+
+// handle dispatches a request or notification, returning the result of requests.
+func (s *server) handle(msg *message) (any, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // Full
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"completionProvider":     map[string]any{"triggerCharacters": []string{" ", ":"}},
+				"codeActionProvider":     true,
+				"executeCommandProvider": map[string]any{"commands": []string{previewCommand}},
+			},
+			"serverInfo": map[string]any{"name": "cgen", "version": strings.TrimSpace(cgen.Version)},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.publish(params.TextDocument.URI, nil)
+		}
+	case "textDocument/hover":
+		return withPosition(s, msg, (*document).hover)
+	case "textDocument/definition":
+		return withPosition(s, msg, (*document).definition)
+	case "textDocument/completion":
+		return withPosition(s, msg, (*document).completion)
+	case "textDocument/codeAction":
+		var params codeActionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{codeInvalidParams, err.Error()}
+		}
+		if params.Range.Start.Line < 0 || params.Range.Start.Character < 0 {
+			return nil, &responseError{codeInvalidParams, "the range cannot start at a negative position"}
+		}
+		if doc := s.documents[params.TextDocument.URI]; doc != nil {
+			return doc.codeActions(doc.runePosition(params.Range.Start)), nil
+		}
+		return []codeAction{}, nil
+	case "workspace/executeCommand":
+		var params executeCommandParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{codeInvalidParams, err.Error()}
+		}
+		return s.execute(params)
+	default:
+		if msg.ID != nil && !strings.HasPrefix(msg.Method, "$/") {
+			return nil, &responseError{codeMethodNotFound, fmt.Sprintf("method %q is not supported", msg.Method)}
+		}
+	}
+	return nil, nil
+}
+
+// withPosition decodes the parameters of a request about a position in a document and answers it
+// with f, or with null if the document is not open.
+func withPosition[T any](s *server, msg *message, f func(*document, position) T) (any, *responseError) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, &responseError{codeInvalidParams, err.Error()}
+	}
+	if params.Position.Line < 0 || params.Position.Character < 0 {
+		return nil, &responseError{codeInvalidParams, "the position cannot be negative"}
+	}
+	doc := s.documents[params.TextDocument.URI]
+	if doc == nil {
+		return nil, nil
+	}
+	return f(doc, doc.runePosition(params.Position)), nil
+}
+
+func (s *server) notify(method string, params any) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	writeMessage(s.w, &message{Method: method, Params: data})
+}
+
+// update decodes the new text of a document and publishes its diagnostics.
+func (s *server) update(uri, text string) {
+	doc := s.documents[uri]
+	if doc == nil {
+		doc = &document{uri: uri}
+		s.documents[uri] = doc
+	}
+	doc.text = text
+	doc.lines = strings.Split(text, "\n")
+
+	file := uriPath(uri)
+	var ds []cgen.Diagnostic
+	spec, err := cgen.ParseSpec(file, []byte(text))
+	var diagnostics *cgen.DiagnosticError
+	if errors.As(err, &diagnostics) {
+		ds = diagnostics.Diagnostics
+		doc.current = false
+	} else if err != nil {
+		ds = []cgen.Diagnostic{{Severity: cgen.SeverityError, Rule: "syntax-error", Message: err.Error()}}
+		doc.current = false
+	} else {
+		doc.spec, doc.current = spec, true
+		// The server cannot know whether the generation will use --env, so the environment variables
+		// are enabled, which also makes the preview closer to the generated files.
+		ds = append(spec.Interpolate(true), spec.Lint()...)
+	}
+
+	s.publish(uri, ds)
+}
+
+// publish publishes the diagnostics of a document, under the URI of the file each is in, and clears
+// the ones previously published for included files that now have none.
+func (s *server) publish(uri string, ds []cgen.Diagnostic) {
+	file := uriPath(uri)
+	published := map[string][]diagnostic{uri: {}}
+	included := []string{}
+	var lines []string
+	if doc := s.documents[uri]; doc != nil {
+		lines = doc.lines
+	}
+	for _, d := range ds {
+		target := uri
+		if d.Position != nil && d.Position.File != file {
+			target = fileURI(d.Position.File)
+		}
+		if _, ok := published[target]; !ok {
+			included = append(included, target)
+		}
+		if target == uri {
+			published[target] = append(published[target], toDiagnostic(d, lines))
+		} else {
+			published[target] = append(published[target], toDiagnostic(d, nil))
+		}
+	}
+	for _, previous := range s.included[uri] {
+		if _, ok := published[previous]; !ok {
+			included = append(included, previous)
+			published[previous] = []diagnostic{}
+		}
+	}
+
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, published[uri]})
+	for _, target := range included {
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{target, published[target]})
+	}
+	s.included[uri] = slices.DeleteFunc(included, func(target string) bool { return len(published[target]) == 0 })
+}
+
+// execute runs a command of a code action.
+func (s *server) execute(params executeCommandParams) (any, *responseError) {
+	if params.Command != previewCommand || len(params.Arguments) != 3 {
+		return nil, &responseError{codeInvalidParams, fmt.Sprintf("unknown command %q", params.Command)}
+	}
+	var uri, path, target string
+	for i, arg := range []*string{&uri, &path, &target} {
+		if err := json.Unmarshal(params.Arguments[i], arg); err != nil {
+			return nil, &responseError{codeInvalidParams, err.Error()}
+		}
+	}
+
+	doc := s.documents[uri]
+	if doc == nil || doc.spec == nil {
+		return nil, &responseError{codeInvalidParams, "the document could not be decoded"}
+	}
+	preview, err := doc.preview(path, target)
+	if err != nil {
+		return nil, &responseError{codeInvalidParams, err.Error()}
+	}
+	s.notify("window/showMessage", showMessageParams{messageTypeInfo, preview})
+	return preview, nil
+}
+
+// uriPath returns the file path of a file URI, or the URI itself if it is not one.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// fileURI returns the file URI of a path.
+func fileURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
@@ -0,0 +1,307 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/acristoffers/cgen/cgen"
+)
+
+const testURI = "file:///tmp/cli.yml"
+
+const testDocument = `name: cli
+long-description: "😀 cli remote add"
+commands:
+  - name: remote
+    commands:
+      - name: add
+  - name: checkout
+arguments:
+  - named:
+`
+
+// request returns a request with the parameters encoded as JSON.
+func request(t *testing.T, id int, method string, params any) *message {
+	t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &message{Method: method, Params: data}
+	if id > 0 {
+		raw := json.RawMessage(fmt.Sprint(id))
+		msg.ID = &raw
+	}
+	return msg
+}
+
+// session opens the document in a server, sends it the requests and returns the responses, by ID,
+// once it exits.
+func session(t *testing.T, text string, requests ...*message) map[string]*message {
+	t.Helper()
+	var in bytes.Buffer
+	open := request(t, 0, "textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": testURI, "languageId": "yaml", "version": 1, "text": text},
+	})
+	messages := append([]*message{open}, requests...)
+	messages = append(messages, request(t, 999, "shutdown", nil), request(t, 0, "exit", nil))
+	for _, msg := range messages {
+		if err := writeMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	responses := map[string]*message{}
+	reader := bufio.NewReader(&out)
+	for {
+		msg, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return responses
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID != nil {
+			responses[string(*msg.ID)] = msg
+		}
+	}
+}
+
+func positionParams(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": testURI},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+// utf16Index returns the position, in UTF-16 code units, of the end of the first occurrence of substr
+// in line.
+func utf16Index(line, substr string) int {
+	i := strings.Index(line, substr) + len(substr)
+	return utf16Column(line, len([]rune(line[:i])))
+}
+
+func TestPositionRequests(t *testing.T) {
+	lines := strings.Split(testDocument, "\n")
+	tests := []struct {
+		name      string
+		method    string
+		line      int
+		character int
+		code      int
+		want      string
+	}{
+		{"hover on a key", "textDocument/hover", 0, 2, 0, "**name** (string)"},
+		{"hover on a nested key", "textDocument/hover", 3, 6, 0, "**name** (string)"},
+		{"hover outside of the document", "textDocument/hover", 50, 0, 0, "null"},
+		{"keys of an argument", "textDocument/completion", 8, 4, 0, `"label":"completion"`},
+		{"values of a boolean", "textDocument/completion", 8, 11, 0, `"label":"true"`},
+		{"subcommands in a description", "textDocument/completion", 1, utf16Index(lines[1], "remote "), 0, `"label":"add"`},
+		{"characters after a surrogate pair", "textDocument/completion", 1, utf16Index(lines[1], "remote"), 0, `"label":"checkout"`},
+		{"character past the end of the line", "textDocument/completion", 3, 200, 0, "[]"},
+		{"negative line", "textDocument/hover", -1, 0, codeInvalidParams, ""},
+		{"negative character", "textDocument/completion", 1, -3, codeInvalidParams, ""},
+		{"negative definition", "textDocument/definition", 0, -1, codeInvalidParams, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := session(t, testDocument, request(t, 1, tt.method, positionParams(tt.line, tt.character)))
+			response := responses["1"]
+			if response == nil {
+				t.Fatal("no response")
+			}
+			if tt.code != 0 {
+				if response.Error == nil || response.Error.Code != tt.code {
+					t.Fatalf("error = %v, want code %d", response.Error, tt.code)
+				}
+				return
+			}
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %v", response.Error)
+			}
+			result := "null"
+			if response.Result != nil {
+				result = string(*response.Result)
+			}
+			if !strings.Contains(result, tt.want) {
+				t.Errorf("result = %s, want it to contain %s", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnosticRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want textRange
+	}{
+		{"ASCII", "name: cli\nnmae: x\n", textRange{position{1, 0}, position{1, 4}}},
+		{"after a surrogate pair", "{name: \"😀\", nmae: x}\n", textRange{position{0, 13}, position{0, 17}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in, out bytes.Buffer
+			writeMessage(&in, request(t, 0, "textDocument/didOpen", map[string]any{
+				"textDocument": map[string]any{"uri": testURI, "text": tt.text},
+			}))
+			if err := Serve(&in, &out); err != nil {
+				t.Fatal(err)
+			}
+			msg, err := readMessage(bufio.NewReader(&out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var params publishDiagnosticsParams
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			i := slices.IndexFunc(params.Diagnostics, func(d diagnostic) bool { return d.Code == "unknown-field" })
+			if i < 0 {
+				t.Fatalf("diagnostics = %v, want an unknown-field", params.Diagnostics)
+			}
+			if got := params.Diagnostics[i].Range; got != tt.want {
+				t.Errorf("range = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnConversions(t *testing.T) {
+	tests := []struct {
+		line      string
+		character int
+		column    int
+	}{
+		{"name: cli", 4, 4},
+		{"a😀b", 1, 1},
+		{"a😀b", 3, 2},
+		{"a😀b", 4, 3},
+		{"é😀", 3, 2},
+		{"ab", 10, 2},
+	}
+	for _, tt := range tests {
+		if got := runeColumn(tt.line, tt.character); got != tt.column {
+			t.Errorf("runeColumn(%q, %d) = %d, want %d", tt.line, tt.character, got, tt.column)
+		}
+		if tt.character <= len(utf16.Encode([]rune(tt.line))) {
+			if got := utf16Column(tt.line, tt.column); got != tt.character {
+				t.Errorf("utf16Column(%q, %d) = %d, want %d", tt.line, tt.column, got, tt.character)
+			}
+		}
+	}
+}
+
+func TestPreviewCommand(t *testing.T) {
+	text := "name: cli\ncommands:\n  - name: add\n    arguments:\n      - {named: true, name: force}\n"
+	tests := []struct {
+		path string
+		code int
+		want string
+	}{
+		{"commands[0].arguments[0]", 0, "-l force"},
+		{"commands[0].arguments[1]", codeInvalidParams, ""},
+		{"commands[0].arguments[-1]", codeInvalidParams, ""},
+		{"commands[-1].arguments[0]", codeInvalidParams, ""},
+		{"arguments[-1]", codeInvalidParams, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			responses := session(t, text, request(t, 1, "workspace/executeCommand", map[string]any{
+				"command":   previewCommand,
+				"arguments": []string{testURI, tt.path, "fish"},
+			}))
+			response := responses["1"]
+			if response == nil {
+				t.Fatal("no response")
+			}
+			if tt.code != 0 {
+				if response.Error == nil || response.Error.Code != tt.code {
+					t.Fatalf("error = %v, want code %d", response.Error, tt.code)
+				}
+				return
+			}
+			if response.Error != nil || response.Result == nil || !strings.Contains(string(*response.Result), tt.want) {
+				t.Errorf("response = %v, want a preview containing %q", response, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"valid", "Content-Length: 2\r\n\r\n{}", true},
+		{"empty body", "Content-Length: 0\r\n\r\n", false},
+		{"missing length", "Content-Type: x\r\n\r\n{}", false},
+		{"negative length", "Content-Length: -1\r\n\r\n{}", false},
+		{"huge length", "Content-Length: 999999999999\r\n\r\n{}", false},
+		{"length over the limit", fmt.Sprintf("Content-Length: %d\r\n\r\n{}", maxMessageSize+1), false},
+		{"truncated body", "Content-Length: 10\r\n\r\n{}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readMessage(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if !tt.ok && err == nil {
+				t.Error("readMessage() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	var in, out bytes.Buffer
+	fmt.Fprintf(&in, "Content-Length: 8\r\n\r\n{\"id\": 1")
+	writeMessage(&in, request(t, 2, "textDocument/hover", positionParams(0, 0)))
+	if err := Serve(&in, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"id":null`) {
+		t.Errorf("the parse error is not answered with a null ID:\n%s", out.String())
+	}
+
+	reader := bufio.NewReader(&out)
+	msg, err := readMessage(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error == nil || msg.Error.Code != codeParseError {
+		t.Errorf("error = %v, want code %d", msg.Error, codeParseError)
+	}
+	// The server goes on to the next message.
+	msg, err = readMessage(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID == nil || string(*msg.ID) != "2" || msg.Error != nil {
+		t.Errorf("response = %v, want the answer to request 2", msg)
+	}
+}
+
+func TestPanickingRequest(t *testing.T) {
+	// A document whose description was not decoded, which the preview does not expect.
+	s := &server{w: io.Discard, documents: map[string]*document{testURI: {uri: testURI, spec: &cgen.Spec{}}}}
+	msg := request(t, 1, "workspace/executeCommand", map[string]any{
+		"command":   previewCommand,
+		"arguments": []string{testURI, "arguments[0]", "fish"},
+	})
+	if _, rerr := s.safeHandle(msg); rerr == nil || rerr.Code != codeInternalError {
+		t.Errorf("error = %v, want code %d", rerr, codeInternalError)
+	}
+}